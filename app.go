@@ -105,6 +105,17 @@ func (a *App) ToggleHabit(habitName string) error {
 	return a.habitService.ToggleHabit(year, week, habitName)
 }
 
+// SkipHabit toggles whether a habit is skipped for the current week
+func (a *App) SkipHabit(habitName string) error {
+	if a.habitService == nil {
+		return fmt.Errorf("habit service not initialized")
+	}
+
+	// Get current week info
+	year, week := getCurrentWeekInfo()
+	return a.habitService.SkipHabit(year, week, habitName)
+}
+
 // AddHabit adds a new habit to the current week
 func (a *App) AddHabit(habitName string) error {
 	if a.habitService == nil {
@@ -19,6 +19,8 @@ export function SaveConfig(arg1:config.Config):Promise<void>;
 
 export function SelectWorkspaceDirectory():Promise<string>;
 
+export function SkipHabit(arg1:string):Promise<void>;
+
 export function ToggleHabit(arg1:string):Promise<void>;
 
 export function ValidateWorkspacePath(arg1:string):Promise<void>;
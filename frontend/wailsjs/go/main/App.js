@@ -34,6 +34,10 @@ export function SelectWorkspaceDirectory() {
   return window['go']['main']['App']['SelectWorkspaceDirectory']();
 }
 
+export function SkipHabit(arg1) {
+  return window['go']['main']['App']['SkipHabit'](arg1);
+}
+
 export function ToggleHabit(arg1) {
   return window['go']['main']['App']['ToggleHabit'](arg1);
 }
@@ -42,6 +42,40 @@ export namespace config {
 		    return a;
 		}
 	}
+	export class TasksConfig {
+	    Statuses: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new TasksConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Statuses = source["Statuses"];
+	    }
+	}
+	export class MarkdownConfig {
+	    BulletMarker: string;
+	    CheckedChar: string;
+	    IndentWidth: number;
+	    OrderedNumbering: string;
+	    BlankLinesAroundHeadings?: number;
+	    TrailingNewline: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new MarkdownConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.BulletMarker = source["BulletMarker"];
+	        this.CheckedChar = source["CheckedChar"];
+	        this.IndentWidth = source["IndentWidth"];
+	        this.OrderedNumbering = source["OrderedNumbering"];
+	        this.BlankLinesAroundHeadings = source["BlankLinesAroundHeadings"];
+	        this.TrailingNewline = source["TrailingNewline"];
+	    }
+	}
 	export class PeriodicNotes {
 	    WeeklySubdir: string;
 	    WeeklyNameFormat: string;
@@ -60,6 +94,8 @@ export namespace config {
 	    WorkspaceRoot: string;
 	    PeriodicNotes: PeriodicNotes;
 	    WeeklyView: WeeklyViewConfig;
+	    Tasks: TasksConfig;
+	    Markdown: MarkdownConfig;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -70,6 +106,8 @@ export namespace config {
 	        this.WorkspaceRoot = source["WorkspaceRoot"];
 	        this.PeriodicNotes = this.convertValues(source["PeriodicNotes"], PeriodicNotes);
 	        this.WeeklyView = this.convertValues(source["WeeklyView"], WeeklyViewConfig);
+	        this.Tasks = this.convertValues(source["Tasks"], TasksConfig);
+	        this.Markdown = this.convertValues(source["Markdown"], MarkdownConfig);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -100,7 +138,9 @@ export namespace habits {
 	export class Habit {
 	    name: string;
 	    completed: boolean;
+	    skipped: boolean;
 	    order: number;
+	    line?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new Habit(source);
@@ -110,7 +150,9 @@ export namespace habits {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.name = source["name"];
 	        this.completed = source["completed"];
+	        this.skipped = source["skipped"];
 	        this.order = source["order"];
+	        this.line = source["line"];
 	    }
 	}
 	export class WeeklyHabits {
@@ -118,6 +160,7 @@ export namespace habits {
 	    week_number: number;
 	    habits: Record<string, Habit>;
 	    day_status: Record<string, boolean>;
+	    warnings?: string[];
 	
 	    static createFrom(source: any = {}) {
 	        return new WeeklyHabits(source);
@@ -129,6 +172,7 @@ export namespace habits {
 	        this.week_number = source["week_number"];
 	        this.habits = this.convertValues(source["habits"], Habit, true);
 	        this.day_status = source["day_status"];
+	        this.warnings = source["warnings"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -9,6 +9,8 @@ type Config struct {
 	WorkspaceRoot string           `yaml:"workspace_root"`
 	PeriodicNotes PeriodicNotes    `yaml:"periodic_notes"`
 	WeeklyView    WeeklyViewConfig `yaml:"weekly_view"`
+	Tasks         TasksConfig      `yaml:"tasks"`
+	Markdown      MarkdownConfig   `yaml:"markdown"`
 }
 
 type PeriodicNotes struct {
@@ -16,6 +18,25 @@ type PeriodicNotes struct {
 	WeeklyNameFormat string `yaml:"weekly_name_format"`
 }
 
+type TasksConfig struct {
+	// Statuses maps additional checkbox characters to status names, on top
+	// of the built in todo, done and bullet journal states
+	Statuses map[string]string `yaml:"statuses"`
+}
+
+// MarkdownConfig sets the style of markdown the planner writes. Empty values
+// use the writer's defaults.
+type MarkdownConfig struct {
+	BulletMarker     string `yaml:"bullet_marker"`     // "-", "*" or "+"
+	CheckedChar      string `yaml:"checked_char"`      // "x" or "X"
+	IndentWidth      int    `yaml:"indent_width"`      // spaces per nested list level
+	OrderedNumbering string `yaml:"ordered_numbering"` // "sequential" or "lazy"
+	// BlankLinesAroundHeadings is a pointer so that 0 can be told apart
+	// from unset
+	BlankLinesAroundHeadings *int `yaml:"blank_lines_around_headings"`
+	TrailingNewline          bool `yaml:"trailing_newline"`
+}
+
 type WeeklyViewConfig struct {
 	EnabledComponents WeeklyViewComponents `yaml:"enabled_components"`
 }
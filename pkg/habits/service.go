@@ -5,10 +5,12 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/notedownorg/planner/pkg/config"
 	"github.com/notedownorg/planner/pkg/markdown"
+	"github.com/notedownorg/planner/pkg/markdown/diff"
 	"github.com/notedownorg/planner/pkg/markdown/reader"
 	"github.com/notedownorg/planner/pkg/markdown/writer"
 )
@@ -16,12 +18,19 @@ import (
 // Service manages habit tracking with markdown persistence
 type Service struct {
 	config *config.Config
+
+	// loaded holds the content of each weekly file as it was when its
+	// habits were last loaded or saved, so that edits made to the file in
+	// the meantime can be merged rather than overwritten
+	mu     sync.Mutex
+	loaded map[string]string
 }
 
 // NewService creates a new habit service
 func NewService(cfg *config.Config) *Service {
 	return &Service{
 		config: cfg,
+		loaded: make(map[string]string),
 	}
 }
 
@@ -58,10 +67,11 @@ func (s *Service) LoadWeeklyHabits(year int, weekNumber int) (*WeeklyHabits, err
 	}
 
 	// Parse markdown
-	doc, err := reader.ParseMarkdown(string(content))
+	doc, err := reader.ParseMarkdown(string(content), reader.WithTaskStatuses(s.taskStatuses()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse markdown: %w", err)
 	}
+	s.setLoaded(filePath, string(content))
 
 	// Extract habits from markdown
 	return s.extractHabitsFromDocument(doc, year, weekNumber)
@@ -79,35 +89,84 @@ func (s *Service) SaveWeeklyHabits(habits *WeeklyHabits) error {
 
 	// Read existing content if file exists
 	var doc *markdown.Document
+	var conflicts []diff.Conflict
 	if _, err := os.Stat(filePath); err == nil {
 		content, err := os.ReadFile(filePath)
 		if err != nil {
 			return fmt.Errorf("failed to read existing file: %w", err)
 		}
 
-		doc, err = reader.ParseMarkdown(string(content))
+		doc, err = reader.ParseMarkdown(string(content), reader.WithTaskStatuses(s.taskStatuses()))
 		if err != nil {
 			return fmt.Errorf("failed to parse existing markdown: %w", err)
 		}
+
+		// If the file was edited since the habits were loaded, merge the
+		// habit changes into it rather than overwriting the edits
+		if base, ok := s.getLoaded(filePath); ok && base != string(content) {
+			conflicts, err = s.mergeHabitsSection(base, doc, habits)
+			if err != nil {
+				return fmt.Errorf("failed to merge habits section: %w", err)
+			}
+		} else if err := s.updateHabitsSection(doc, habits); err != nil {
+			return fmt.Errorf("failed to update habits section: %w", err)
+		}
 	} else {
 		// Create new document
 		doc = s.createNewWeeklyDocument(habits.Year, habits.WeekNumber)
-	}
-
-	// Update or create habits section
-	if err := s.updateHabitsSection(doc, habits); err != nil {
-		return fmt.Errorf("failed to update habits section: %w", err)
+		if err := s.updateHabitsSection(doc, habits); err != nil {
+			return fmt.Errorf("failed to update habits section: %w", err)
+		}
 	}
 
 	// Write back to file
-	content := writer.WriteDocument(doc)
+	content := writer.WriteDocumentWithOptions(doc, s.writerOptions())
 	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	s.setLoaded(filePath, content)
 
+	if len(conflicts) > 0 {
+		return &ConflictError{WeekNumber: habits.WeekNumber, Conflicts: conflicts}
+	}
 	return nil
 }
 
+// mergeHabitsSection applies habits to base, the file content they were
+// loaded from, and merges the result into doc, the file as it is now
+func (s *Service) mergeHabitsSection(base string, doc *markdown.Document, habits *WeeklyHabits) ([]diff.Conflict, error) {
+	baseDoc, err := reader.ParseMarkdown(base, reader.WithTaskStatuses(s.taskStatuses()))
+	if err != nil {
+		return nil, err
+	}
+	ours, err := reader.ParseMarkdown(base, reader.WithTaskStatuses(s.taskStatuses()))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateHabitsSection(ours, habits); err != nil {
+		return nil, err
+	}
+	return diff.Merge(baseDoc, ours, doc), nil
+}
+
+// getLoaded returns the content of a weekly file when it was last loaded
+// or saved
+func (s *Service) getLoaded(filePath string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.loaded[filePath]
+	return content, ok
+}
+
+func (s *Service) setLoaded(filePath, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded == nil {
+		s.loaded = make(map[string]string)
+	}
+	s.loaded[filePath] = content
+}
+
 // createNewWeeklyHabits creates a new weekly habits structure with defaults
 func (s *Service) createNewWeeklyHabits(year int, weekNumber int) (*WeeklyHabits, error) {
 	// Get default habits from previous week or config
@@ -177,7 +236,7 @@ func (s *Service) getDefaultHabitsWithoutRecursion(year int, weekNumber int) []s
 	}
 
 	// Parse markdown
-	doc, err := reader.ParseMarkdown(string(content))
+	doc, err := reader.ParseMarkdown(string(content), reader.WithTaskStatuses(s.taskStatuses()))
 	if err != nil {
 		return []string{}
 	}
@@ -216,8 +275,10 @@ func (s *Service) extractHabitsFromDocument(doc *markdown.Document, year int, we
 		DayStatus:  make(map[string]bool),
 	}
 
-	// Find the Habits heading
-	habitsHeading := markdown.FindHeadingByTitle(doc, "Habits")
+	habitsHeading, err := findHabitsHeading(doc, weekNumber)
+	if err != nil {
+		return nil, err
+	}
 	if habitsHeading == nil {
 		// No habits section found, return empty but valid structure
 		return habits, nil
@@ -229,9 +290,25 @@ func (s *Service) extractHabitsFromDocument(doc *markdown.Document, year int, we
 		// Use the entire task content as the habit name
 		if len(task.Content) > 0 {
 			habitName := task.Content
+			line := 0
+			if r, ok := task.Range(); ok {
+				line = r.Start.Line
+			}
+			if _, exists := habits.Habits[habitName]; exists {
+				// Only the last task with the same name is kept, so the
+				// others are dropped on the next save
+				warning := &LocationError{
+					WeekNumber: weekNumber,
+					Line:       line,
+					Err:        fmt.Errorf("duplicate habit %q", habitName),
+				}
+				habits.Warnings = append(habits.Warnings, warning.Error())
+			}
 			habits.Habits[habitName] = &Habit{
 				Name:      habitName,
-				Completed: task.Checked,
+				Completed: task.Checked(),
+				Skipped:   task.Status == markdown.StatusCancelled,
+				Line:      line,
 				Order:     i, // Use index to preserve order from file
 			}
 		}
@@ -242,22 +319,21 @@ func (s *Service) extractHabitsFromDocument(doc *markdown.Document, year int, we
 
 // updateHabitsSection updates the habits section in a markdown document
 func (s *Service) updateHabitsSection(doc *markdown.Document, habits *WeeklyHabits) error {
-	// Find or create the main week heading
-	weekTitle := fmt.Sprintf("Week %02d", habits.WeekNumber)
-	weekHeading := markdown.FindHeadingByTitle(doc, weekTitle)
-	if weekHeading == nil {
-		weekHeading = markdown.NewHeading(1, weekTitle)
-		doc.AddChild(weekHeading)
+	// Find the Habits heading the habits were loaded from, or create one
+	// under the main week heading
+	habitsHeading, err := findHabitsHeading(doc, habits.WeekNumber)
+	if err != nil {
+		return err
 	}
-
-	// Find or create the Habits heading
-	habitsHeading := markdown.FindHeadingByTitle(weekHeading, "Habits")
 	if habitsHeading == nil {
+		weekTitle := fmt.Sprintf("Week %02d", habits.WeekNumber)
+		weekHeading := markdown.FindHeadingByTitle(doc, weekTitle)
+		if weekHeading == nil {
+			weekHeading = markdown.NewHeading(1, weekTitle)
+			doc.AddChild(weekHeading)
+		}
 		habitsHeading = markdown.NewHeading(2, "Habits")
 		weekHeading.AddChild(habitsHeading)
-	} else {
-		// Clear existing children to avoid duplicates when updating
-		habitsHeading.ClearChildren()
 	}
 
 	// Sort habits by order before adding them
@@ -267,23 +343,169 @@ func (s *Service) updateHabitsSection(doc *markdown.Document, habits *WeeklyHabi
 	}
 	// Sort by completion status first, then by Order field
 	sort.Slice(sortedHabits, func(i, j int) bool {
-		// Incomplete habits come first
-		if sortedHabits[i].Completed != sortedHabits[j].Completed {
-			return !sortedHabits[i].Completed
+		// Incomplete habits come first, skipped ones count as settled
+		// for the week alongside completed ones
+		iDone := sortedHabits[i].Completed || sortedHabits[i].Skipped
+		jDone := sortedHabits[j].Completed || sortedHabits[j].Skipped
+		if iDone != jDone {
+			return !iDone
 		}
 		// Within same completion status, sort by order
 		return sortedHabits[i].Order < sortedHabits[j].Order
 	})
 
-	// Add habit tasks in sorted order
+	// Existing tasks are reused so any checklists under them survive
+	existing := make(map[string]*markdown.Task)
+	for _, task := range markdown.FindTasks(habitsHeading) {
+		existing[task.Content] = task
+	}
+
+	var tasks []*markdown.Task
 	for _, habit := range sortedHabits {
-		task := markdown.NewTask(habit.Completed, habit.Name)
-		habitsHeading.AddChild(task)
+		task, ok := existing[habit.Name]
+		if ok {
+			task.Status = habitStatus(habit, task.Status)
+		} else {
+			task = markdown.NewTaskWithStatus(habitStatus(habit, markdown.StatusTodo), habit.Name)
+		}
+		tasks = append(tasks, task)
+	}
+
+	placeHabitTasks(habitsHeading, tasks)
+	return nil
+}
+
+// findHabitsHeading returns the Habits heading under a week's heading, or
+// nil if the week has none. A Habits heading elsewhere in the file is
+// reported rather than used, since it may belong to another section.
+func findHabitsHeading(doc *markdown.Document, weekNumber int) (*markdown.Heading, error) {
+	weekTitle := fmt.Sprintf("Week %02d", weekNumber)
+	if matches, err := markdown.Select(doc, "# "+weekTitle+" > ## Habits"); err == nil && len(matches) > 0 {
+		return matches[0].(*markdown.Heading), nil
+	}
+	if weekHeading := markdown.FindHeadingByTitle(doc, weekTitle); weekHeading != nil {
+		if habitsHeading := markdown.FindHeadingByTitle(weekHeading, "Habits"); habitsHeading != nil {
+			return habitsHeading, nil
+		}
+	}
+
+	if other := markdown.FindHeadingByTitle(doc, "Habits"); other != nil {
+		line := 0
+		if r, ok := other.Range(); ok {
+			line = r.Start.Line
+		}
+		return nil, &LocationError{
+			WeekNumber: weekNumber,
+			Line:       line,
+			Err:        fmt.Errorf("heading \"Habits\" is not under %q", weekTitle),
+		}
+	}
+	return nil, nil
+}
+
+// placeHabitTasks arranges the habit tasks under the heading in order. The
+// tasks fill the positions the existing habit tasks occupied, so notes
+// between them are left where they are; any extra tasks follow the last
+// one, and positions left over are removed.
+func placeHabitTasks(heading *markdown.Heading, tasks []*markdown.Task) {
+	// Swap each existing task for a placeholder first, so that moving a
+	// task does not shift the positions still to be filled
+	var slots []markdown.Node
+	for _, task := range markdown.FindTasks(heading) {
+		slot := markdown.NewText("")
+		task.ReplaceWith(slot)
+		slots = append(slots, slot)
+	}
+
+	var last markdown.Node
+	for i, slot := range slots {
+		parent := slot.Parent()
+		if i >= len(tasks) {
+			parent.RemoveChild(slot)
+			// Drop lists that no longer hold anything
+			if list, ok := parent.(*markdown.List); ok && len(list.Children()) == 0 && list.Parent() != nil {
+				list.Parent().RemoveChild(list)
+			}
+			continue
+		}
+		slot.ReplaceWith(tasks[i])
+		last = tasks[i]
+	}
+
+	for _, task := range tasks[min(len(slots), len(tasks)):] {
+		if last != nil {
+			last.Parent().InsertAfter(task, last)
+		} else if sub := firstSubheading(heading); sub != nil {
+			// Keep new tasks out of any subsections
+			heading.InsertBefore(task, sub)
+		} else {
+			heading.AddChild(task)
+		}
+		last = task
 	}
+}
 
+// firstSubheading returns the first heading nested directly under heading
+func firstSubheading(heading *markdown.Heading) *markdown.Heading {
+	for _, child := range heading.Children() {
+		if sub, ok := child.(*markdown.Heading); ok {
+			return sub
+		}
+	}
 	return nil
 }
 
+// habitStatus returns the task status for a habit. Other states such as in
+// progress are kept while the habit is neither completed nor skipped.
+func habitStatus(habit *Habit, current markdown.TaskStatus) markdown.TaskStatus {
+	switch {
+	case habit.Completed:
+		if current.Done() {
+			return current
+		}
+		return markdown.StatusDone
+	case habit.Skipped:
+		return markdown.StatusCancelled
+	case current.Done() || current == markdown.StatusCancelled:
+		return markdown.StatusTodo
+	}
+	return current
+}
+
+// taskStatuses returns the checkbox states recognised in weekly notes
+func (s *Service) taskStatuses() markdown.TaskStatusSet {
+	statuses := markdown.DefaultTaskStatuses()
+	for char, name := range s.config.Tasks.Statuses {
+		if runes := []rune(char); len(runes) == 1 {
+			statuses[markdown.TaskStatus(runes[0])] = name
+		}
+	}
+	return statuses
+}
+
+// writerOptions returns the markdown style configured for written files
+func (s *Service) writerOptions() writer.Options {
+	opts := writer.DefaultOptions()
+	cfg := s.config.Markdown
+	if cfg.BulletMarker != "" {
+		opts.BulletMarker = cfg.BulletMarker
+	}
+	// Other characters would not be read back as done
+	switch cfg.CheckedChar {
+	case "x", "X":
+		opts.CheckedChar = rune(cfg.CheckedChar[0])
+	}
+	opts.IndentWidth = cfg.IndentWidth
+	if cfg.OrderedNumbering != "" {
+		opts.OrderedNumbering = writer.Numbering(cfg.OrderedNumbering)
+	}
+	if cfg.BlankLinesAroundHeadings != nil {
+		opts.BlankLinesAroundHeadings = *cfg.BlankLinesAroundHeadings
+	}
+	opts.TrailingNewline = cfg.TrailingNewline
+	return opts
+}
+
 // GetCurrentWeekHabits gets habits for the current week
 func (s *Service) GetCurrentWeekHabits() (*WeeklyHabits, error) {
 	year, week := getCurrentWeekInfo()
@@ -299,6 +521,23 @@ func (s *Service) ToggleHabit(year int, weekNumber int, habitName string) error
 
 	if habit, exists := habits.Habits[habitName]; exists {
 		habit.Completed = !habit.Completed
+		habit.Skipped = false
+	}
+
+	return s.SaveWeeklyHabits(habits)
+}
+
+// SkipHabit toggles whether a habit is skipped for the week. Skipping a
+// habit clears its completion.
+func (s *Service) SkipHabit(year int, weekNumber int, habitName string) error {
+	habits, err := s.LoadWeeklyHabits(year, weekNumber)
+	if err != nil {
+		return err
+	}
+
+	if habit, exists := habits.Habits[habitName]; exists {
+		habit.Skipped = !habit.Skipped
+		habit.Completed = false
 	}
 
 	return s.SaveWeeklyHabits(habits)
@@ -1,6 +1,7 @@
 package habits
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -354,6 +355,158 @@ func TestGetDefaultHabitsFromPreviousWeek(t *testing.T) {
 	assert.False(t, week2Habits.Habits["Read"].Completed) // Should be reset to incomplete
 }
 
+func TestSubtasksUnderHabits(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 01\n\n## Habits\n\n- [ ] Exercise\n  - [x] Stretch\n  - [ ] Run\n- [ ] Read\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	// Subtasks are a checklist under their habit, not habits themselves
+	habits, err := service.LoadWeeklyHabits(2024, 1)
+	require.NoError(t, err)
+	assert.Len(t, habits.Habits, 2)
+	assert.NotNil(t, habits.Habits["Exercise"])
+	assert.NotNil(t, habits.Habits["Read"])
+
+	err = service.ToggleHabit(2024, 1, "Read")
+	require.NoError(t, err)
+
+	updated, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n\n## Habits\n\n- [ ] Exercise\n  - [x] Stretch\n  - [ ] Run\n- [x] Read\n", string(updated))
+
+	// The checklist stays with its habit when the habit is rewritten
+	err = service.ToggleHabit(2024, 1, "Exercise")
+	require.NoError(t, err)
+
+	updated, err = os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n\n## Habits\n\n- [x] Exercise\n  - [x] Stretch\n  - [ ] Run\n- [x] Read\n", string(updated))
+}
+
+func TestSkipHabit(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 01\n\n## Habits\n\n- [x] Exercise\n- [/] Read\n- [-] Meditate\n- [ ] Journal\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	habits, err := service.LoadWeeklyHabits(2024, 1)
+	require.NoError(t, err)
+	assert.True(t, habits.Habits["Meditate"].Skipped)
+	assert.False(t, habits.Habits["Meditate"].Completed)
+	assert.False(t, habits.Habits["Read"].Skipped)
+
+	// Skipping clears completion and moves the habit with the settled ones
+	err = service.SkipHabit(2024, 1, "Exercise")
+	require.NoError(t, err)
+
+	updated, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n\n## Habits\n\n- [/] Read\n- [ ] Journal\n- [-] Exercise\n- [-] Meditate\n", string(updated))
+
+	// Completing a skipped habit un-skips it
+	err = service.ToggleHabit(2024, 1, "Meditate")
+	require.NoError(t, err)
+
+	habits, err = service.LoadWeeklyHabits(2024, 1)
+	require.NoError(t, err)
+	assert.True(t, habits.Habits["Meditate"].Completed)
+	assert.False(t, habits.Habits["Meditate"].Skipped)
+	assert.True(t, habits.Habits["Exercise"].Skipped)
+}
+
+func TestLoadWeeklyHabitsWithThematicBreaks(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "---\n# Week 01\n\n## Habits\n\n- [x] Exercise\n\n---\n\nNotes\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	// A file opening with a thematic break is not front matter
+	habits, err := service.LoadWeeklyHabits(2024, 1)
+	require.NoError(t, err)
+	require.NotNil(t, habits.Habits["Exercise"])
+	assert.True(t, habits.Habits["Exercise"].Completed)
+}
+
+func TestCustomTaskStatuses(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+	service.config.Tasks.Statuses = map[string]string{"~": "partial"}
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 01\n\n## Habits\n\n- [~] Exercise\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	habits, err := service.LoadWeeklyHabits(2024, 1)
+	require.NoError(t, err)
+	require.NotNil(t, habits.Habits["Exercise"])
+	assert.False(t, habits.Habits["Exercise"].Completed)
+}
+
+func TestUpdateHabitsKeepsNotes(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 01\n\n## Habits\n\nMorning routine:\n\n- [ ] Exercise\n- [ ] Stretch\n\nEvening routine:\n\n- [ ] Read\n\n### Log\n\nSlept badly on Tuesday.\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	// Completing a habit moves it to the end without disturbing the notes
+	require.NoError(t, service.ToggleHabit(2024, 1, "Exercise"))
+	updated, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n\n## Habits\n\nMorning routine:\n\n- [ ] Stretch\n- [ ] Read\n\nEvening routine:\n\n- [x] Exercise\n\n### Log\n\nSlept badly on Tuesday.\n", string(updated))
+
+	// New habits follow the last one and removed habits leave no trace
+	require.NoError(t, service.AddHabit(2024, 1, "Journal"))
+	require.NoError(t, service.RemoveHabit(2024, 1, "Read"))
+	updated, err = os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n\n## Habits\n\nMorning routine:\n\n- [ ] Stretch\n- [ ] Journal\n\nEvening routine:\n\n- [x] Exercise\n\n### Log\n\nSlept badly on Tuesday.\n", string(updated))
+}
+
+func TestAddHabitBeforeSubsections(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 01\n\n## Habits\n\n### Log\n\nNothing yet.\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	require.NoError(t, service.AddHabit(2024, 1, "Exercise"))
+	updated, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n\n## Habits\n\n- [ ] Exercise\n\n### Log\n\nNothing yet.\n", string(updated))
+}
+
+func TestToggleHabitKeepsLineEndings(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "\uFEFF# Week 01\r\n\r\n## Habits\r\n\r\n- [ ] Exercise\r\n- [ ] Read\r\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	// A file edited on Windows is written back with its own line endings
+	require.NoError(t, service.ToggleHabit(2024, 1, "Read"))
+	updated, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "\uFEFF# Week 01\r\n\r\n## Habits\r\n\r\n- [ ] Exercise\r\n- [x] Read\r\n", string(updated))
+}
+
 // Helper function
 func indexOf(s, substr string) int {
 	for i := 0; i <= len(s)-len(substr); i++ {
@@ -363,3 +516,190 @@ func indexOf(s, substr string) int {
 	}
 	return -1
 }
+
+func TestLoadHabitsUnderWeekHeading(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Notes\n\n## Habits\n\n- [ ] Not a habit\n\n# Week 01\n\n## Habits\n\n- [x] Exercise\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	habits, err := service.LoadWeeklyHabits(2024, 1)
+	require.NoError(t, err)
+	assert.Len(t, habits.Habits, 1)
+	require.NotNil(t, habits.Habits["Exercise"])
+	assert.True(t, habits.Habits["Exercise"].Completed)
+
+	// Saving updates the same section
+	require.NoError(t, service.ToggleHabit(2024, 1, "Exercise"))
+	saved, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Notes\n\n## Habits\n\n- [ ] Not a habit\n\n# Week 01\n\n## Habits\n\n- [ ] Exercise\n", string(saved))
+}
+
+func TestHabitsOutsideWeekHeading(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"No week heading", "## Habits\n\n- [ ] Exercise\n", `Week 01, line 1: heading "Habits" is not under "Week 01"`},
+		{"Under another section", "# Notes\n\n## Habits\n\n- [ ] Not a habit\n\n# Week 01\n", `Week 01, line 3: heading "Habits" is not under "Week 01"`},
+	}
+
+	filePath := service.GetWeeklyFilePath(2024, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filePath, []byte(tt.content), 0644))
+
+			// The habits are neither read from nor written to the wrong place
+			_, err := service.LoadWeeklyHabits(2024, 1)
+			var locationErr *LocationError
+			require.True(t, errors.As(err, &locationErr))
+			assert.Equal(t, tt.expected, err.Error())
+
+			assert.Error(t, service.ToggleHabit(2024, 1, "Exercise"))
+			saved, err := os.ReadFile(filePath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.content, string(saved))
+		})
+	}
+}
+
+func TestDuplicateHabitReportsLine(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 42)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 42\n\n## Habits\n\n- [x] Exercise\n- [ ] Read\n- [ ] Exercise\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	// A duplicate is reported without failing the load
+	habits, err := service.LoadWeeklyHabits(2024, 42)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`Week 42, line 7: duplicate habit "Exercise"`}, habits.Warnings)
+	assert.Len(t, habits.Habits, 2)
+	assert.Equal(t, 7, habits.Habits["Exercise"].Line)
+	assert.Equal(t, 6, habits.Habits["Read"].Line)
+
+	// Habits can still be changed and become defaults for the next week
+	require.NoError(t, service.ToggleHabit(2024, 42, "Read"))
+	assert.ElementsMatch(t, []string{"Exercise", "Read"}, service.getDefaultHabitsWithoutRecursion(2024, 43))
+}
+
+func TestLocationErrorWithoutLine(t *testing.T) {
+	err := &LocationError{WeekNumber: 42, Err: errors.New("duplicate habit \"Exercise\"")}
+	assert.Equal(t, `Week 42: duplicate habit "Exercise"`, err.Error())
+}
+
+func TestSaveMergesExternalEdits(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 42)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week."
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	habits, err := service.LoadWeeklyHabits(2024, 42)
+	require.NoError(t, err)
+
+	// The file is edited elsewhere while the habits are loaded
+	edited := "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [x] Journal\n\n## Notes\n\nQuiet week.\n\nWent hiking."
+	require.NoError(t, os.WriteFile(filePath, []byte(edited), 0644))
+
+	habits.Habits["Gym"].Completed = true
+	require.NoError(t, service.SaveWeeklyHabits(habits))
+
+	saved, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 42\n\n## Habits\n\n- [ ] Read\n- [x] Journal\n- [x] Gym\n\n## Notes\n\nQuiet week.\n\nWent hiking.", string(saved))
+}
+
+func TestSaveReportsConflicts(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+
+	filePath := service.GetWeeklyFilePath(2024, 42)
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+	content := "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	habits, err := service.LoadWeeklyHabits(2024, 42)
+	require.NoError(t, err)
+
+	edited := "# Week 42\n\n## Habits\n\n- [-] Gym\n- [ ] Read"
+	require.NoError(t, os.WriteFile(filePath, []byte(edited), 0644))
+
+	habits.Habits["Gym"].Completed = true
+	err = service.SaveWeeklyHabits(habits)
+
+	var conflictErr *ConflictError
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Len(t, conflictErr.Conflicts, 1)
+	assert.Equal(t, `Week 42 was edited while it was open: task "Gym" in Week 42 > Habits: changed on both sides (status)`, err.Error())
+
+	// The edit made in the file wins
+	saved, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, edited, string(saved))
+}
+
+func TestSaveUsesMarkdownStyle(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+	noBlankLines := 0
+	service.config.Markdown = config.MarkdownConfig{
+		BulletMarker:             "*",
+		CheckedChar:              "X",
+		BlankLinesAroundHeadings: &noBlankLines,
+		TrailingNewline:          true,
+	}
+
+	habits := &WeeklyHabits{
+		Year:       2024,
+		WeekNumber: 1,
+		Habits: map[string]*Habit{
+			"Exercise": {Name: "Exercise", Completed: true, Order: 0},
+			"Read":     {Name: "Read", Order: 1},
+		},
+		DayStatus: map[string]bool{},
+	}
+	require.NoError(t, service.SaveWeeklyHabits(habits))
+
+	saved, err := os.ReadFile(service.GetWeeklyFilePath(2024, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n## Habits\n* [ ] Read\n* [X] Exercise\n", string(saved))
+}
+
+func TestSaveIgnoresInvalidCheckedChar(t *testing.T) {
+	service, tempDir := createTestService(t)
+	defer os.RemoveAll(tempDir)
+	service.config.Markdown = config.MarkdownConfig{CheckedChar: "v"}
+
+	habits := &WeeklyHabits{
+		Year:       2024,
+		WeekNumber: 1,
+		Habits: map[string]*Habit{
+			"Exercise": {Name: "Exercise", Completed: true, Order: 0},
+		},
+		DayStatus: map[string]bool{},
+	}
+	require.NoError(t, service.SaveWeeklyHabits(habits))
+
+	saved, err := os.ReadFile(service.GetWeeklyFilePath(2024, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "# Week 01\n\n## Habits\n\n- [x] Exercise", string(saved))
+
+	// The habit is still read back as done
+	loaded, err := service.LoadWeeklyHabits(2024, 1)
+	require.NoError(t, err)
+	assert.True(t, loaded.Habits["Exercise"].Completed)
+}
@@ -1,20 +1,32 @@
 package habits
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
 
-// Habit represents a single habit with a name, completion status, and order
+	"github.com/notedownorg/planner/pkg/markdown/diff"
+)
+
+// Habit represents a single habit with a name, completion status, and order.
+// A skipped habit was deliberately not done and is written as a cancelled
+// task ("- [-]"). Line is where the habit's task is in the weekly file, or 0
+// if it has not been written yet.
 type Habit struct {
 	Name      string `json:"name"`
 	Completed bool   `json:"completed"`
+	Skipped   bool   `json:"skipped"`
 	Order     int    `json:"order"`
+	Line      int    `json:"line,omitempty"`
 }
 
 // WeeklyHabits represents habits for a specific week
 type WeeklyHabits struct {
 	Year       int               `json:"year"`
 	WeekNumber int               `json:"week_number"`
-	Habits     map[string]*Habit `json:"habits"`     // key is habit name
-	DayStatus  map[string]bool   `json:"day_status"` // tracks which days have been marked
+	Habits     map[string]*Habit `json:"habits"`             // key is habit name
+	DayStatus  map[string]bool   `json:"day_status"`         // tracks which days have been marked
+	Warnings   []string          `json:"warnings,omitempty"` // problems found while loading, such as duplicate habits
 }
 
 // HabitDay represents habits for a specific day
@@ -27,3 +39,36 @@ type HabitDay struct {
 type HabitConfig struct {
 	DefaultHabits []string `json:"default_habits"` // list of habit names
 }
+
+// LocationError is a problem found at a particular line of a weekly file. A
+// Line of 0 means the line is not known.
+type LocationError struct {
+	WeekNumber int
+	Line       int
+	Err        error
+}
+
+func (e *LocationError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("Week %02d: %v", e.WeekNumber, e.Err)
+	}
+	return fmt.Sprintf("Week %02d, line %d: %v", e.WeekNumber, e.Line, e.Err)
+}
+
+func (e *LocationError) Unwrap() error { return e.Err }
+
+// ConflictError reports habit changes that could not be saved because the
+// weekly file was edited in the same places since it was loaded. The file
+// keeps the edits; everything else was saved.
+type ConflictError struct {
+	WeekNumber int
+	Conflicts  []diff.Conflict
+}
+
+func (e *ConflictError) Error() string {
+	descriptions := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		descriptions[i] = conflict.String()
+	}
+	return fmt.Sprintf("Week %02d was edited while it was open: %s", e.WeekNumber, strings.Join(descriptions, "; "))
+}
@@ -0,0 +1,466 @@
+// Package diff compares two markdown trees and reports the changes between
+// them at the level of blocks: headings, tasks, list items, paragraphs and
+// so on. Inline edits show up as changes to the Content or Title of their
+// block.
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+)
+
+// Kind is the kind of a change
+type Kind string
+
+const (
+	Added    Kind = "added"
+	Removed  Kind = "removed"
+	Modified Kind = "modified"
+	Moved    Kind = "moved"
+)
+
+// Change is a single difference between two trees
+type Change struct {
+	Kind Kind
+
+	// Old is the node in the old tree, or nil if it was added. New is the
+	// node in the new tree, or nil if it was removed.
+	Old markdown.Node
+	New markdown.Node
+
+	// OldPath and NewPath are the titles of the headings containing the
+	// node in each tree
+	OldPath []string
+	NewPath []string
+
+	// Fields lists the fields that differ for Modified changes. Front
+	// matter changes list the keys that differ.
+	Fields []FieldChange
+}
+
+// FieldChange is a field whose value differs between two nodes
+type FieldChange struct {
+	Name string
+	Old  any
+	New  any
+}
+
+// entry is a block in a flattened tree
+type entry struct {
+	node     markdown.Node
+	parent   *entry
+	children []*entry
+	index    int // position among the parent's children
+	path     []string
+	key      string
+	match    *entry
+}
+
+// Diff returns the changes that turn old into new. Removals come first, in
+// the old tree's order, followed by additions, modifications and moves in
+// the new tree's order. A node added or removed along with its parent is
+// not reported separately. Lists are looked through, so their items are
+// compared as if they belonged to the list's parent.
+func Diff(old, new markdown.Node) []Change {
+	oldRoot, oldEntries := flatten(old)
+	newRoot, newEntries := flatten(new)
+	oldRoot.match, newRoot.match = newRoot, oldRoot
+	match(oldEntries, newEntries)
+
+	var changes []Change
+	if change, ok := diffFrontMatter(old, new); ok {
+		changes = append(changes, change)
+	}
+
+	for _, e := range oldEntries {
+		if e.match == nil && e.parent.match != nil {
+			changes = append(changes, Change{Kind: Removed, Old: e.node, OldPath: e.path})
+		}
+	}
+
+	moved := reordered(newRoot)
+	for _, e := range newEntries {
+		if e.match == nil {
+			if e.parent.match != nil {
+				changes = append(changes, Change{Kind: Added, New: e.node, NewPath: e.path})
+			}
+			continue
+		}
+
+		change := Change{Old: e.match.node, New: e.node, OldPath: e.match.path, NewPath: e.path}
+		if fields := diffFields(e.match.node, e.node); len(fields) > 0 {
+			modified := change
+			modified.Kind = Modified
+			modified.Fields = fields
+			changes = append(changes, modified)
+		}
+		if e.match.parent != e.parent.match || moved[e] {
+			change.Kind = Moved
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// Format renders changes as human-readable text, one change per line
+func Format(changes []Change) string {
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String describes the change, such as
+// `modified task "Gym" in Week 42 > Habits: status " " -> "x"`
+func (c Change) String() string {
+	node, path := c.New, c.NewPath
+	if c.Kind == Removed {
+		node, path = c.Old, c.OldPath
+	}
+
+	var b strings.Builder
+	b.WriteString(string(c.Kind) + " " + describe(node))
+
+	switch {
+	case c.Kind == Moved && strings.Join(c.OldPath, "\x00") != strings.Join(c.NewPath, "\x00"):
+		b.WriteString(" from " + formatPath(c.OldPath) + " to " + formatPath(c.NewPath))
+	case len(path) > 0:
+		b.WriteString(" in " + formatPath(path))
+	}
+
+	if len(c.Fields) > 0 {
+		fields := make([]string, len(c.Fields))
+		for i, field := range c.Fields {
+			fields[i] = formatField(field)
+		}
+		b.WriteString(": " + strings.Join(fields, ", "))
+	}
+	return b.String()
+}
+
+// flatten returns an entry for root and entries for the blocks beneath it
+// in document order
+func flatten(root markdown.Node) (*entry, []*entry) {
+	rootEntry := &entry{node: root}
+	var entries []*entry
+	collect(root, rootEntry, nil, &entries)
+	return rootEntry, entries
+}
+
+func collect(node markdown.Node, parent *entry, path []string, entries *[]*entry) {
+	for _, child := range node.Children() {
+		if _, ok := child.(*markdown.List); ok {
+			collect(child, parent, path, entries)
+			continue
+		}
+
+		e := &entry{node: child, parent: parent, index: len(parent.children), path: path, key: key(child)}
+		parent.children = append(parent.children, e)
+		*entries = append(*entries, e)
+
+		switch n := child.(type) {
+		case *markdown.TableRow:
+			// Rows are compared by their cells
+			continue
+		case *markdown.Heading:
+			collect(child, e, append(append([]string(nil), path...), n.Title), entries)
+		default:
+			collect(child, e, path, entries)
+		}
+	}
+}
+
+// match pairs up the entries of two trees. Blocks with the same type and
+// text are matched first, in document order; blocks left over are paired
+// with an unmatched block of the same type at the same position under the
+// same parent, which catches edits to their text.
+func match(oldEntries, newEntries []*entry) {
+	byKey := map[string][]*entry{}
+	for _, e := range oldEntries {
+		byKey[e.key] = append(byKey[e.key], e)
+	}
+	for _, e := range newEntries {
+		if candidates := byKey[e.key]; len(candidates) > 0 {
+			e.match, candidates[0].match = candidates[0], e
+			byKey[e.key] = candidates[1:]
+		}
+	}
+
+	for _, e := range newEntries {
+		if e.match != nil || e.parent.match == nil {
+			continue
+		}
+		siblings := e.parent.match.children
+		if e.index < len(siblings) {
+			candidate := siblings[e.index]
+			if candidate.match == nil && candidate.node.Type() == e.node.Type() {
+				e.match, candidate.match = candidate, e
+			}
+		}
+	}
+}
+
+// reordered returns the entries beneath parent that changed position
+// relative to their siblings. The longest run of siblings that kept their
+// relative order stays put and the rest count as moved.
+func reordered(parent *entry) map[*entry]bool {
+	moved := map[*entry]bool{}
+
+	var stayed []*entry
+	for _, child := range parent.children {
+		if child.match != nil && parent.match != nil && child.match.parent == parent.match {
+			stayed = append(stayed, child)
+		}
+	}
+	indices := make([]int, len(stayed))
+	for i, child := range stayed {
+		indices[i] = child.match.index
+	}
+	inOrder := longestIncreasing(indices)
+	for i, child := range stayed {
+		if !inOrder[i] {
+			moved[child] = true
+		}
+	}
+
+	for _, child := range parent.children {
+		for e := range reordered(child) {
+			moved[e] = true
+		}
+	}
+	return moved
+}
+
+// longestIncreasing reports which values belong to a longest strictly
+// increasing subsequence. Ties go to the subsequence that ends earliest, so
+// when two siblings swap places the later one is the one that moved.
+func longestIncreasing(values []int) []bool {
+	// tails[k] is the index of the smallest tail of an increasing
+	// subsequence of length k+1
+	var tails []int
+	prev := make([]int, len(values))
+	best := -1
+	for i, v := range values {
+		k := sort.Search(len(tails), func(k int) bool { return values[tails[k]] >= v })
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+			// The first subsequence to reach a new length ends earliest
+			best = i
+		} else {
+			tails[k] = i
+		}
+	}
+
+	result := make([]bool, len(values))
+	for i := best; i >= 0; i = prev[i] {
+		result[i] = true
+	}
+	return result
+}
+
+// key identifies a block across trees by its type and text. Tasks are
+// identified by their description so that editing their metadata or
+// status still matches them, and blocks with a block reference ID by the
+// ID so that any edit does.
+func key(node markdown.Node) string {
+	if id := markdown.BlockID(node); id != "" {
+		return string(node.Type()) + ":^" + id
+	}
+	var text string
+	switch n := node.(type) {
+	case *markdown.Heading:
+		text = strings.ToLower(strings.TrimSpace(n.Title))
+	case *markdown.Task:
+		text = n.Description()
+	case *markdown.ListItem:
+		text = n.Content
+	case *markdown.Paragraph:
+		text = n.Content
+	case *markdown.CodeBlock:
+		text = n.Content
+	case *markdown.RawBlock:
+		text = n.Content
+	case *markdown.Callout:
+		text = n.Title
+		if text == "" {
+			text = n.Kind
+		}
+	case *markdown.TableRow:
+		text = rowText(n)
+	}
+	return string(node.Type()) + ":" + text
+}
+
+// rowText joins the contents of a table row's cells
+func rowText(row *markdown.TableRow) string {
+	cells := row.Cells()
+	contents := make([]string, len(cells))
+	for i, cell := range cells {
+		contents[i] = cell.Content
+	}
+	return strings.Join(contents, " | ")
+}
+
+// diffFields compares the exported fields of two nodes of the same type.
+// Inline nodes are skipped since they mirror the raw text fields, and
+// generated heading IDs since they follow the title.
+func diffFields(old, new markdown.Node) []FieldChange {
+	if old.Type() != new.Type() {
+		return []FieldChange{{Name: "Type", Old: old.Type(), New: new.Type()}}
+	}
+
+	var fields []FieldChange
+	if oldRow, ok := old.(*markdown.TableRow); ok {
+		// Cells are children rather than fields
+		if before, after := rowText(oldRow), rowText(new.(*markdown.TableRow)); before != after {
+			fields = append(fields, FieldChange{Name: "Cells", Old: before, New: after})
+		}
+	}
+
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous || !field.IsExported() || field.Name == "Inlines" || field.Name == "AutoID" {
+			continue
+		}
+		before, after := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if !reflect.DeepEqual(before, after) {
+			fields = append(fields, FieldChange{Name: field.Name, Old: before, New: after})
+		}
+	}
+	return fields
+}
+
+// diffFrontMatter compares the front matter of two documents
+func diffFrontMatter(old, new markdown.Node) (Change, bool) {
+	var before, after *markdown.FrontMatter
+	if doc, ok := old.(*markdown.Document); ok {
+		before = doc.FrontMatter
+	}
+	if doc, ok := new.(*markdown.Document); ok {
+		after = doc.FrontMatter
+	}
+
+	switch {
+	case before == nil && after == nil:
+		return Change{}, false
+	case before == nil:
+		return Change{Kind: Added, New: after}, true
+	case after == nil:
+		return Change{Kind: Removed, Old: before}, true
+	}
+
+	var fields []FieldChange
+	for _, key := range before.Keys() {
+		oldValue, _ := before.Get(key)
+		newValue, ok := after.Get(key)
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			fields = append(fields, FieldChange{Name: key, Old: oldValue, New: newValue})
+		}
+	}
+	for _, key := range after.Keys() {
+		if _, ok := before.Get(key); !ok {
+			newValue, _ := after.Get(key)
+			fields = append(fields, FieldChange{Name: key, New: newValue})
+		}
+	}
+	if len(fields) == 0 {
+		return Change{}, false
+	}
+	return Change{Kind: Modified, Old: before, New: after, Fields: fields}, true
+}
+
+// describe names a node for display, such as `task "Gym"`
+func describe(node markdown.Node) string {
+	var text string
+	switch n := node.(type) {
+	case *markdown.FrontMatter:
+		return "front matter"
+	case *markdown.Heading:
+		text = n.Title
+	case *markdown.Task:
+		text = n.Description()
+	case *markdown.ListItem:
+		text = n.Content
+	case *markdown.Paragraph:
+		text = n.Content
+	case *markdown.CodeBlock:
+		text = n.Content
+	case *markdown.RawBlock:
+		text = n.Content
+	case *markdown.Callout:
+		text = n.Title
+		if text == "" {
+			text = n.Kind
+		}
+	case *markdown.TableRow:
+		text = rowText(n)
+	}
+
+	name := strings.ReplaceAll(string(node.Type()), "_", " ")
+	if text == "" {
+		return name
+	}
+	return name + " " + strconv.Quote(truncate(text))
+}
+
+// truncate shortens text to its first line and at most 40 characters
+func truncate(text string) string {
+	text, _, cut := strings.Cut(text, "\n")
+	if runes := []rune(text); len(runes) > 40 {
+		return string(runes[:39]) + "…"
+	} else if cut {
+		return text + "…"
+	}
+	return text
+}
+
+// formatPath renders a heading path such as "Week 42 > Habits"
+func formatPath(path []string) string {
+	if len(path) == 0 {
+		return "the top level"
+	}
+	return strings.Join(path, " > ")
+}
+
+// formatField renders a field change, including the values when they are
+// simple enough to show inline
+func formatField(field FieldChange) string {
+	name := strings.ToLower(field.Name)
+	before, ok := formatValue(field.Old)
+	if !ok {
+		return name
+	}
+	after, ok := formatValue(field.New)
+	if !ok {
+		return name
+	}
+	return fmt.Sprintf("%s %s -> %s", name, before, after)
+}
+
+func formatValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "none", true
+	case fmt.Stringer:
+		return strconv.Quote(v.String()), true
+	case string:
+		return strconv.Quote(truncate(v)), true
+	case bool, int, float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
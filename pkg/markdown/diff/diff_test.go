@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+	"github.com/notedownorg/planner/pkg/markdown/reader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected []string
+	}{
+		{
+			name:     "No changes",
+			old:      "# Week 42\n\n## Habits\n\n- [ ] Gym\n",
+			new:      "# Week 42\n\n## Habits\n\n- [ ] Gym\n",
+			expected: nil,
+		},
+		{
+			name: "Task toggled",
+			old:  "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n",
+			new:  "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [x] Read\n",
+			expected: []string{
+				`modified task "Read" in Week 42 > Habits: status " " -> "x"`,
+			},
+		},
+		{
+			name: "Tasks added and removed",
+			old:  "## Habits\n\n- [ ] Gym\n- [ ] Read\n",
+			new:  "## Habits\n\n- [ ] Gym\n\nSome notes\n\n- [ ] Meditate\n",
+			expected: []string{
+				`removed task "Read" in Habits`,
+				`added paragraph "Some notes" in Habits`,
+				`added task "Meditate" in Habits`,
+			},
+		},
+		{
+			name: "Task edited in place",
+			old:  "## Habits\n\n- [ ] Gym\n- [ ] Read\n",
+			new:  "## Habits\n\n- [ ] Gym\n- [ ] Read 20 pages\n",
+			expected: []string{
+				`modified task "Read 20 pages" in Habits: content "Read" -> "Read 20 pages"`,
+			},
+		},
+		{
+			name: "Tasks reordered",
+			old:  "## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n",
+			new:  "## Habits\n\n- [ ] Read\n- [ ] Journal\n- [ ] Gym\n",
+			expected: []string{
+				`moved task "Gym" in Habits`,
+			},
+		},
+		{
+			name: "Heading moved",
+			old:  "# Week 41\n\n## Habits\n\n- [ ] Gym\n\n# Week 42\n",
+			new:  "# Week 41\n\n# Week 42\n\n## Habits\n\n- [ ] Gym\n",
+			expected: []string{
+				`moved heading "Habits" from Week 41 to Week 42`,
+			},
+		},
+		{
+			name: "Section removed with its content",
+			old:  "# Week 42\n\n## Notes\n\nA paragraph\n\n- [ ] Task\n",
+			new:  "# Week 42\n",
+			expected: []string{
+				`removed heading "Notes" in Week 42`,
+			},
+		},
+		{
+			name: "Subtask added",
+			old:  "- [ ] Gym\n",
+			new:  "- [ ] Gym\n  - [ ] Stretch\n",
+			expected: []string{
+				`added task "Stretch"`,
+			},
+		},
+		{
+			name: "Front matter",
+			old:  "---\nstatus: draft\ntags: [a]\n---\n# Week 42\n",
+			new:  "---\nstatus: reviewed\ntags: [a]\nweek: 42\n---\n# Week 42\n",
+			expected: []string{
+				`modified front matter: status "draft" -> "reviewed", week none -> 42`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldDoc, err := reader.ParseMarkdown(tt.old)
+			assert.NoError(t, err)
+			newDoc, err := reader.ParseMarkdown(tt.new)
+			assert.NoError(t, err)
+
+			var descriptions []string
+			for _, change := range Diff(oldDoc, newDoc) {
+				descriptions = append(descriptions, change.String())
+			}
+			assert.Equal(t, tt.expected, descriptions)
+		})
+	}
+}
+
+func TestDiffChanges(t *testing.T) {
+	oldDoc, err := reader.ParseMarkdown("# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n")
+	assert.NoError(t, err)
+	newDoc, err := reader.ParseMarkdown("# Week 42\n\n## Habits\n\n- [x] Read\n- [ ] Gym\n- [ ] Journal\n")
+	assert.NoError(t, err)
+
+	changes := Diff(oldDoc, newDoc)
+	assert.Len(t, changes, 3)
+
+	assert.Equal(t, Modified, changes[0].Kind)
+	assert.Equal(t, []string{"Week 42", "Habits"}, changes[0].NewPath)
+	assert.Equal(t, []FieldChange{{Name: "Status", Old: markdown.StatusTodo, New: markdown.StatusDone}}, changes[0].Fields)
+
+	assert.Equal(t, Moved, changes[1].Kind)
+	assert.Equal(t, Added, changes[2].Kind)
+	assert.Nil(t, changes[2].Old)
+
+	// Of two swapped siblings, the one now later is the one that moved
+	assert.Equal(t, "modified task \"Read\" in Week 42 > Habits: status \" \" -> \"x\"\n"+
+		"moved task \"Gym\" in Week 42 > Habits\n"+
+		"added task \"Journal\" in Week 42 > Habits", Format(changes))
+}
@@ -0,0 +1,437 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+)
+
+// Conflict is a change made on both sides of a merge that could not be
+// combined. The merged tree keeps their version.
+type Conflict struct {
+	// Base, Ours and Theirs are the node on each side, or nil where it does
+	// not exist
+	Base   markdown.Node
+	Ours   markdown.Node
+	Theirs markdown.Node
+
+	// Path is the titles of the headings containing the node
+	Path []string
+
+	// Fields lists the fields or front matter keys both sides changed
+	Fields []string
+
+	Reason string
+}
+
+// String describes the conflict, such as
+// `task "Gym" in Week 42 > Habits: changed on both sides (status)`
+func (c Conflict) String() string {
+	node := c.Ours
+	if node == nil {
+		node = c.Base
+	}
+	if node == nil {
+		node = c.Theirs
+	}
+
+	var b strings.Builder
+	b.WriteString(describe(node))
+	if len(c.Path) > 0 {
+		b.WriteString(" in " + formatPath(c.Path))
+	}
+	b.WriteString(": " + c.Reason)
+	if len(c.Fields) > 0 {
+		fields := make([]string, len(c.Fields))
+		for i, field := range c.Fields {
+			fields[i] = strings.ToLower(field)
+		}
+		b.WriteString(" (" + strings.Join(fields, ", ") + ")")
+	}
+	return b.String()
+}
+
+// merger holds the state of a three-way merge
+type merger struct {
+	// theirs maps base nodes to their match in their tree
+	theirs map[markdown.Node]*entry
+
+	// theirMoves holds the entries of their tree that moved from base
+	theirMoves map[*entry]bool
+
+	// placed holds the entries of our tree whose nodes were added to their
+	// tree, so later additions can be placed relative to them
+	placed map[*entry]bool
+
+	conflicts []Conflict
+}
+
+// Merge combines two sets of changes made to base: ours, the tree base
+// became on one side, and theirs, the tree it became on the other. Our
+// changes are applied to theirs in place, so theirs becomes the merged tree
+// and unchanged parts of it keep their original formatting when written.
+// Nodes we added are moved out of ours.
+//
+// Changes to different nodes, or to different fields of the same node, are
+// combined. When both sides changed the same thing differently, their
+// version is kept and the change is reported as a conflict.
+func Merge(base, ours, theirs markdown.Node) []Conflict {
+	baseForOurs, baseOursEntries := flatten(base)
+	oursRoot, oursEntries := flatten(ours)
+	baseForOurs.match, oursRoot.match = oursRoot, baseForOurs
+	match(baseOursEntries, oursEntries)
+
+	baseForTheirs, baseTheirsEntries := flatten(base)
+	theirsRoot, theirsEntries := flatten(theirs)
+	baseForTheirs.match, theirsRoot.match = theirsRoot, baseForTheirs
+	match(baseTheirsEntries, theirsEntries)
+
+	m := &merger{
+		theirs:     map[markdown.Node]*entry{base: theirsRoot},
+		theirMoves: moves(theirsRoot, theirsEntries),
+		placed:     map[*entry]bool{},
+	}
+	for _, e := range baseTheirsEntries {
+		m.theirs[e.node] = e.match
+	}
+
+	m.mergeFrontMatter(base, ours, theirs)
+
+	// Removals first, so that anchors for additions and moves are nodes
+	// that will remain
+	for _, b := range baseOursEntries {
+		if b.match == nil && b.parent.match != nil {
+			m.remove(b)
+		}
+	}
+
+	ourMoves := moves(oursRoot, oursEntries)
+	for _, o := range oursEntries {
+		switch {
+		case o.match == nil && o.parent.match != nil:
+			m.add(o)
+		case o.match != nil:
+			// A node that conflicts keeps their version, including its place
+			if m.modify(o) && ourMoves[o] {
+				m.move(o)
+			}
+		}
+	}
+
+	return m.conflicts
+}
+
+// moves returns the entries that moved relative to base, either to another
+// parent or among their siblings
+func moves(root *entry, entries []*entry) map[*entry]bool {
+	moved := reordered(root)
+	for _, e := range entries {
+		if e.match != nil && e.match.parent != e.parent.match {
+			moved[e] = true
+		}
+	}
+	return moved
+}
+
+// remove deletes their copy of a node we removed, unless they changed it
+func (m *merger) remove(b *entry) {
+	t := m.theirs[b.node]
+	if t == nil {
+		// Removed on both sides
+		return
+	}
+	if m.changed(b.node, t) {
+		m.conflict(b.node, nil, t.node, b.path, nil, "removed in ours but changed in theirs")
+		return
+	}
+	if parent := t.node.Parent(); parent != nil {
+		parent.RemoveChild(t.node)
+		m.detach(t)
+	}
+}
+
+// changed reports whether their node or anything beneath it differs from
+// the base node
+func (m *merger) changed(base markdown.Node, t *entry) bool {
+	if len(diffFields(base, t.node)) > 0 || m.theirMoves[t] {
+		return true
+	}
+	b := t.match
+	if b == nil || len(b.children) != len(t.children) {
+		return true
+	}
+	for _, child := range t.children {
+		if child.match == nil || child.match.parent != b || m.changed(child.match.node, child) {
+			return true
+		}
+	}
+	return false
+}
+
+// modify applies the fields we changed to their copy of the node,
+// reporting whether it did so without conflict
+func (m *merger) modify(o *entry) bool {
+	b := o.match
+	fields := diffFields(b.node, o.node)
+	if len(fields) == 0 {
+		return true
+	}
+
+	t := m.theirs[b.node]
+	if t == nil {
+		m.conflict(b.node, o.node, nil, o.path, nil, "changed in ours but removed in theirs")
+		return false
+	}
+
+	theirFields := map[string]FieldChange{}
+	for _, field := range diffFields(b.node, t.node) {
+		theirFields[field.Name] = field
+	}
+
+	var conflicting []string
+	for _, field := range fields {
+		if theirs, ok := theirFields[field.Name]; ok {
+			if !reflect.DeepEqual(theirs.New, field.New) {
+				conflicting = append(conflicting, field.Name)
+			}
+			continue
+		}
+		setField(t.node, o.node, field.Name)
+	}
+	if len(conflicting) > 0 {
+		m.conflict(b.node, o.node, t.node, o.path, conflicting, "changed on both sides")
+		return false
+	}
+	return true
+}
+
+// setField copies a field from one node to another of the same type
+func setField(dst, src markdown.Node, name string) {
+	if name == "Cells" {
+		// Table cells are children, so the row takes our cells
+		dst.ClearChildren()
+		for _, cell := range append([]markdown.Node(nil), src.Children()...) {
+			forgetSource(cell)
+			dst.AddChild(cell)
+		}
+		return
+	}
+
+	field := reflect.ValueOf(dst).Elem().FieldByName(name)
+	field.Set(reflect.ValueOf(src).Elem().FieldByName(name))
+	if name == "Content" || name == "Title" {
+		// The inline nodes were parsed from the old text
+		if container, ok := dst.(markdown.InlineContainer); ok {
+			container.SetInlines()
+		}
+	}
+}
+
+// add places a node we added into their tree
+func (m *merger) add(o *entry) {
+	parent := m.theirParent(o)
+	if parent == nil {
+		m.conflict(nil, o.node, nil, o.path, nil, "added in ours under a section removed in theirs")
+		return
+	}
+
+	// They may have added the same node in the same place
+	for _, t := range parent.children {
+		if t.match == nil && t.key == o.key {
+			return
+		}
+	}
+
+	forgetSource(o.node)
+	m.place(o.node, o, parent)
+	m.placed[o] = true
+}
+
+// move repositions their copy of a node we moved, unless they moved it too
+func (m *merger) move(o *entry) {
+	t := m.theirs[o.match.node]
+	if t == nil {
+		// Already reported as a conflict when the node is changed, and
+		// otherwise simply stays removed
+		return
+	}
+	parent := m.theirParent(o)
+	if parent == nil {
+		m.conflict(o.match.node, o.node, t.node, o.path, nil, "moved in ours under a section removed in theirs")
+		return
+	}
+	if m.theirMoves[t] {
+		if t.parent != parent {
+			m.conflict(o.match.node, o.node, t.node, o.path, nil, "moved to different places on both sides")
+		}
+		return
+	}
+
+	m.place(t.node, o, parent)
+}
+
+// theirParent returns the entry in their tree that corresponds to our
+// node's parent, or nil if there is none
+func (m *merger) theirParent(o *entry) *entry {
+	if o.parent.match == nil {
+		return nil
+	}
+	return m.theirs[o.parent.match.node]
+}
+
+// place inserts node into parent's section of their tree at the position
+// our node o has among its siblings: after the nearest preceding sibling
+// that also exists on their side, or failing that before the nearest
+// following one
+func (m *merger) place(node markdown.Node, o *entry, parent *entry) {
+	siblings := o.parent.children
+	for i := o.index - 1; i >= 0; i-- {
+		if anchor := m.anchor(siblings[i], parent); anchor != nil && anchor != node {
+			anchor.Parent().InsertAfter(node, anchor)
+			return
+		}
+	}
+	for i := o.index + 1; i < len(siblings); i++ {
+		if anchor := m.anchor(siblings[i], parent); anchor != nil && anchor != node {
+			anchor.Parent().InsertBefore(node, anchor)
+			return
+		}
+	}
+
+	// No siblings in common, so the node goes at the end of the section,
+	// inside a list if it was in one
+	container := parent.node
+	if list, ok := o.node.Parent().(*markdown.List); ok {
+		var last *markdown.List
+		for _, child := range container.Children() {
+			if l, ok := child.(*markdown.List); ok {
+				last = l
+			}
+		}
+		if last == nil {
+			last = markdown.NewList(list.Ordered)
+			last.Start, last.Marker = list.Start, list.Marker
+			container.AddChild(last)
+		}
+		container = last
+	}
+	container.AddChild(node)
+}
+
+// anchor returns the node in their tree that corresponds to our sibling s,
+// if it is in the given section of their tree
+func (m *merger) anchor(s *entry, parent *entry) markdown.Node {
+	if m.placed[s] {
+		return s.node
+	}
+	if s.match == nil {
+		return nil
+	}
+	if t := m.theirs[s.match.node]; t != nil && t.parent == parent && t.node.Parent() != nil {
+		return t.node
+	}
+	return nil
+}
+
+// detach marks an entry of their tree as no longer part of it, so it is
+// not used as an anchor
+func (m *merger) detach(t *entry) {
+	for node, e := range m.theirs {
+		if e == t {
+			delete(m.theirs, node)
+		}
+	}
+}
+
+// mergeFrontMatter applies the front matter keys we changed to theirs
+func (m *merger) mergeFrontMatter(base, ours, theirs markdown.Node) {
+	baseDoc, ok1 := base.(*markdown.Document)
+	oursDoc, ok2 := ours.(*markdown.Document)
+	theirsDoc, ok3 := theirs.(*markdown.Document)
+	if !ok1 || !ok2 || !ok3 {
+		return
+	}
+
+	change, ok := diffFrontMatter(baseDoc, oursDoc)
+	if !ok {
+		return
+	}
+	if theirsDoc.FrontMatter == nil {
+		if baseDoc.FrontMatter == nil {
+			theirsDoc.FrontMatter = markdown.NewFrontMatter()
+		} else {
+			m.conflict(baseDoc.FrontMatter, oursDoc.FrontMatter, nil, nil, nil, "changed in ours but removed in theirs")
+			return
+		}
+	}
+
+	theirChange, _ := diffFrontMatter(baseDoc, theirsDoc)
+	theirFields := map[string]FieldChange{}
+	for _, field := range theirChange.Fields {
+		theirFields[field.Name] = field
+	}
+
+	var fields []FieldChange
+	switch change.Kind {
+	case Added:
+		for _, key := range oursDoc.FrontMatter.Keys() {
+			value, _ := oursDoc.FrontMatter.Get(key)
+			fields = append(fields, FieldChange{Name: key, New: value})
+		}
+	case Removed:
+		for _, key := range baseDoc.FrontMatter.Keys() {
+			value, _ := baseDoc.FrontMatter.Get(key)
+			fields = append(fields, FieldChange{Name: key, Old: value})
+		}
+	default:
+		fields = change.Fields
+	}
+
+	var conflicting []string
+	for _, field := range fields {
+		if theirs, ok := theirFields[field.Name]; ok {
+			if !reflect.DeepEqual(theirs.New, field.New) {
+				conflicting = append(conflicting, field.Name)
+			}
+			continue
+		}
+		if oursDoc.FrontMatter != nil {
+			if _, ok := oursDoc.FrontMatter.Get(field.Name); ok {
+				theirsDoc.FrontMatter.Set(field.Name, field.New)
+				continue
+			}
+		}
+		theirsDoc.FrontMatter.Delete(field.Name)
+	}
+	if len(theirsDoc.FrontMatter.Keys()) == 0 {
+		theirsDoc.FrontMatter = nil
+	}
+	if len(conflicting) > 0 {
+		m.conflict(baseDoc.FrontMatter, oursDoc.FrontMatter, theirsDoc.FrontMatter, nil, conflicting, "changed on both sides")
+	}
+}
+
+func (m *merger) conflict(base, ours, theirs markdown.Node, path []string, fields []string, reason string) {
+	m.conflicts = append(m.conflicts, Conflict{
+		Base:   base,
+		Ours:   ours,
+		Theirs: theirs,
+		Path:   path,
+		Fields: fields,
+		Reason: reason,
+	})
+}
+
+// forgetSource clears the spans of a node and everything beneath it, so
+// that nodes taken from another tree are rendered rather than copied from a
+// source they did not come from
+func forgetSource(node markdown.Node) {
+	markdown.Walk(node, func(n markdown.Node, entering bool) markdown.WalkStatus {
+		if entering {
+			n.ClearSpan()
+			n.MarkModified()
+		}
+		return markdown.WalkContinue
+	})
+}
@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/notedownorg/planner/pkg/markdown/reader"
+	"github.com/notedownorg/planner/pkg/markdown/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	base := "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week."
+
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		expected  string
+		conflicts []string
+	}{
+		{
+			name:     "Changes to different nodes",
+			ours:     "# Week 42\n\n## Habits\n\n- [x] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			theirs:   "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [x] Read\n- [ ] Journal\n\n## Notes\n\nQuiet *week*.\n\nWent hiking.",
+			expected: "# Week 42\n\n## Habits\n\n- [x] Gym\n- [x] Read\n- [ ] Journal\n\n## Notes\n\nQuiet *week*.\n\nWent hiking.",
+		},
+		{
+			name:     "Added on our side",
+			ours:     "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Meditate\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			theirs:   "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.\nWent hiking.",
+			expected: "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Meditate\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.\nWent hiking.",
+		},
+		{
+			name:     "Added on both sides",
+			ours:     "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n- [ ] Stretch\n\n## Notes\n\nQuiet week.",
+			theirs:   "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n- [ ] Stretch\n\n## Notes\n\nQuiet week.",
+			expected: "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n- [ ] Stretch\n\n## Notes\n\nQuiet week.",
+		},
+		{
+			name:     "Removed on our side",
+			ours:     "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			theirs:   "# Week 42\n\n## Habits\n\n- [x] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			expected: "# Week 42\n\n## Habits\n\n- [x] Gym\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+		},
+		{
+			name:     "Reordered on our side",
+			ours:     "# Week 42\n\n## Habits\n\n- [ ] Read\n- [ ] Journal\n- [x] Gym\n\n## Notes\n\nQuiet week.",
+			theirs:   "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [x] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			expected: "# Week 42\n\n## Habits\n\n- [x] Read\n- [ ] Journal\n- [x] Gym\n\n## Notes\n\nQuiet week.",
+		},
+		{
+			name:      "Changed on both sides",
+			ours:      "# Week 42\n\n## Habits\n\n- [x] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			theirs:    "# Week 42\n\n## Habits\n\n- [-] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			expected:  "# Week 42\n\n## Habits\n\n- [-] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.",
+			conflicts: []string{`task "Gym" in Week 42 > Habits: changed on both sides (status)`},
+		},
+		{
+			name:      "Removed on our side but changed on theirs",
+			ours:      "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal",
+			theirs:    "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.\nWent hiking.",
+			expected:  "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.\nWent hiking.",
+			conflicts: []string{`heading "Notes" in Week 42: removed in ours but changed in theirs`},
+		},
+		{
+			name:      "Added under a removed section",
+			ours:      "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal\n\n## Notes\n\nQuiet week.\n\nWent hiking.",
+			theirs:    "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal",
+			expected:  "# Week 42\n\n## Habits\n\n- [ ] Gym\n- [ ] Read\n- [ ] Journal",
+			conflicts: []string{`paragraph "Went hiking." in Week 42 > Notes: added in ours under a section removed in theirs`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseDoc, err := reader.ParseMarkdown(base)
+			assert.NoError(t, err)
+			oursDoc, err := reader.ParseMarkdown(tt.ours)
+			assert.NoError(t, err)
+			theirsDoc, err := reader.ParseMarkdown(tt.theirs)
+			assert.NoError(t, err)
+
+			var conflicts []string
+			for _, conflict := range Merge(baseDoc, oursDoc, theirsDoc) {
+				conflicts = append(conflicts, conflict.String())
+			}
+			assert.Equal(t, tt.conflicts, conflicts)
+			assert.Equal(t, tt.expected, writer.WriteDocument(theirsDoc))
+		})
+	}
+}
+
+func TestMergeFrontMatter(t *testing.T) {
+	baseDoc, err := reader.ParseMarkdown("---\nstatus: draft\nweek: 42\n---\n# Week 42")
+	assert.NoError(t, err)
+	oursDoc, err := reader.ParseMarkdown("---\nstatus: draft\nweek: 42\nmood: good\n---\n# Week 42")
+	assert.NoError(t, err)
+	theirsDoc, err := reader.ParseMarkdown("---\nstatus: reviewed\nweek: 42\n---\n# Week 42")
+	assert.NoError(t, err)
+
+	conflicts := Merge(baseDoc, oursDoc, theirsDoc)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, "---\nstatus: reviewed\nweek: 42\nmood: good\n---\n# Week 42", writer.WriteDocument(theirsDoc))
+}
@@ -0,0 +1,210 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter represents the YAML front matter at the top of a document.
+// Fields holds the decoded values; key order from the source is preserved
+// when the front matter is rendered again.
+type FrontMatter struct {
+	BaseNode
+	Fields map[string]any
+
+	// keys is the order keys were parsed or added in
+	keys []string
+
+	// original holds the parsed key and value nodes so that unchanged
+	// values keep their original style and comments
+	original map[string][2]*yaml.Node
+}
+
+func NewFrontMatter() *FrontMatter {
+	f := &FrontMatter{
+		BaseNode: BaseNode{
+			NodeType: NodeFrontMatter,
+			children: []Node{},
+		},
+		Fields:   map[string]any{},
+		original: map[string][2]*yaml.Node{},
+	}
+	f.init(f)
+	return f
+}
+
+// ParseFrontMatter decodes the YAML between the front matter delimiters
+func ParseFrontMatter(content string) (*FrontMatter, error) {
+	f := NewFrontMatter()
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		// Empty front matter
+		return f, nil
+	}
+
+	mapping := root.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("front matter must be a mapping, got %s", mapping.Tag)
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode, valueNode := mapping.Content[i], mapping.Content[i+1]
+
+		var value any
+		if err := valueNode.Decode(&value); err != nil {
+			return nil, fmt.Errorf("front matter key %q: %w", keyNode.Value, err)
+		}
+
+		f.Set(keyNode.Value, value)
+		f.original[keyNode.Value] = [2]*yaml.Node{keyNode, valueNode}
+	}
+
+	return f, nil
+}
+
+// Keys returns the front matter keys in their original order, followed by
+// any keys added directly to Fields in sorted order
+func (f *FrontMatter) Keys() []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, key := range f.keys {
+		if _, ok := f.Fields[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	var extra []string
+	for key := range f.Fields {
+		if !seen[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+
+	return append(keys, extra...)
+}
+
+// Get returns the value for key
+func (f *FrontMatter) Get(key string) (any, bool) {
+	value, ok := f.Fields[key]
+	return value, ok
+}
+
+// GetString returns the value for key if it is a string
+func (f *FrontMatter) GetString(key string) (string, bool) {
+	value, ok := f.Fields[key].(string)
+	return value, ok
+}
+
+// GetStrings returns the value for key as a list of strings. A single
+// string value is returned as a one element list.
+func (f *FrontMatter) GetStrings(key string) ([]string, bool) {
+	switch value := f.Fields[key].(type) {
+	case string:
+		return []string{value}, true
+	case []string:
+		return value, true
+	case []any:
+		var result []string
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, s)
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+// Set stores a value, appending new keys after the existing ones
+func (f *FrontMatter) Set(key string, value any) {
+	if f.Fields == nil {
+		f.Fields = map[string]any{}
+	}
+	if _, ok := f.Fields[key]; !ok {
+		f.keys = append(f.keys, key)
+	}
+	f.Fields[key] = value
+}
+
+// Delete removes a key
+func (f *FrontMatter) Delete(key string) {
+	delete(f.Fields, key)
+	delete(f.original, key)
+	for i, k := range f.keys {
+		if k == key {
+			f.keys = append(f.keys[:i], f.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// YAML encodes the front matter fields in key order, without delimiters.
+// Values that are unchanged since parsing keep their original formatting.
+// Values that cannot be encoded are left out and reported in the error.
+func (f *FrontMatter) YAML() (string, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	var encodeErr error
+
+	for _, key := range f.Keys() {
+		value := f.Fields[key]
+
+		if nodes, ok := f.original[key]; ok {
+			var parsed any
+			if err := nodes[1].Decode(&parsed); err == nil && reflect.DeepEqual(parsed, value) {
+				mapping.Content = append(mapping.Content, nodes[0], nodes[1])
+				continue
+			}
+		}
+
+		valueNode, err := encodeValue(value)
+		if err != nil {
+			if encodeErr == nil {
+				encodeErr = fmt.Errorf("front matter key %q: %w", key, err)
+			}
+			continue
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		mapping.Content = append(mapping.Content, keyNode, valueNode)
+	}
+
+	if len(mapping.Content) == 0 {
+		return "", encodeErr
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(mapping); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), encodeErr
+}
+
+// encodeValue encodes a front matter value as a YAML node. The encoder
+// panics on values such as functions and channels, which are reported as
+// errors instead.
+func encodeValue(value any) (node *yaml.Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			node, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	node = &yaml.Node{}
+	err = node.Encode(value)
+	return node, err
+}
@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	fm, err := ParseFrontMatter("title: Week 42\ntags: [weekly, review]\nreviewed: false\n")
+	require.NoError(t, err)
+
+	assert.Equal(t, NodeFrontMatter, fm.Type())
+	assert.Equal(t, []string{"title", "tags", "reviewed"}, fm.Keys())
+
+	title, ok := fm.GetString("title")
+	assert.True(t, ok)
+	assert.Equal(t, "Week 42", title)
+
+	tags, ok := fm.GetStrings("tags")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"weekly", "review"}, tags)
+
+	reviewed, ok := fm.Get("reviewed")
+	assert.True(t, ok)
+	assert.Equal(t, false, reviewed)
+
+	_, err = ParseFrontMatter("- not\n- a mapping\n")
+	assert.Error(t, err)
+}
+
+func TestFrontMatterYAML(t *testing.T) {
+	fm, err := ParseFrontMatter("title: Week 42\ntags: [weekly, review] # keep me\nstatus: draft\n")
+	require.NoError(t, err)
+
+	// Unchanged values keep their original style and comments
+	out, err := fm.YAML()
+	require.NoError(t, err)
+	assert.Equal(t, "title: Week 42\ntags: [weekly, review] # keep me\nstatus: draft\n", out)
+
+	// Edited keys stay in place and new keys are appended
+	fm.Set("status", "done")
+	fm.Set("aliases", []string{"W42"})
+	fm.Delete("title")
+
+	out, err = fm.YAML()
+	require.NoError(t, err)
+	assert.Equal(t, "tags: [weekly, review] # keep me\nstatus: done\naliases:\n  - W42\n", out)
+
+	// Values that cannot be encoded are left out and reported
+	fm.Set("callback", func() {})
+	out, err = fm.YAML()
+	assert.EqualError(t, err, `front matter key "callback": cannot marshal type: func()`)
+	assert.Equal(t, "tags: [weekly, review] # keep me\nstatus: done\naliases:\n  - W42\n", out)
+}
@@ -0,0 +1,534 @@
+// Package html renders markdown trees as HTML for previewing and publishing
+// notes. All text is escaped and raw HTML in the source is shown as text
+// rather than passed through, so the output is safe to insert into a page.
+package html
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Render converts a document to HTML. Front matter is not rendered.
+//
+// Task checkboxes are rendered as inputs carrying a data-node-id attribute
+// that NodeID returns for the task, so clicks can be mapped back to the task
+// with FindNode.
+func Render(doc *markdown.Document) string {
+	r := &renderer{ids: nodeIDs(doc)}
+	r.renderChildren(doc)
+	return r.String()
+}
+
+// RenderNode converts a single node and its descendants to HTML
+func RenderNode(node markdown.Node) string {
+	r := &renderer{ids: nodeIDs(treeRoot(node))}
+	if isBlock(node) {
+		r.renderBlock(node)
+	} else {
+		r.renderInlines([]markdown.Node{node})
+	}
+	return r.String()
+}
+
+// renderer accumulates the HTML for a tree
+type renderer struct {
+	strings.Builder
+	ids map[markdown.Node]string // NodeID of every block in the tree
+}
+
+// renderChildren renders the block children of a node. Items that are not
+// inside a list, such as tasks added directly under a heading, are wrapped
+// in one so they render as a list.
+func (r *renderer) renderChildren(parent markdown.Node) {
+	_, inList := parent.(*markdown.List)
+	open := false
+	for _, child := range parent.Children() {
+		item := isItem(child) && !inList
+		if item && !open {
+			r.WriteString("<ul>\n")
+		} else if !item && open {
+			r.WriteString("</ul>\n")
+		}
+		open = item
+		r.renderBlock(child)
+	}
+	if open {
+		r.WriteString("</ul>\n")
+	}
+}
+
+// renderBlock renders a block node and its children
+func (r *renderer) renderBlock(node markdown.Node) {
+	switch n := node.(type) {
+	case *markdown.Document:
+		r.renderChildren(n)
+
+	case *markdown.Heading:
+		level := strconv.Itoa(min(max(n.Level, 1), 6))
+		r.WriteString("<h" + level + headingIDAttr(n) + ">")
+		r.renderContent(n, n.Title)
+		r.WriteString("</h" + level + ">\n")
+		r.renderChildren(n)
+
+	case *markdown.Paragraph:
+		r.WriteString("<p" + blockIDAttr(n) + ">")
+		r.renderContent(n, n.Content)
+		r.WriteString("</p>\n")
+
+	case *markdown.List:
+		tag := "ul"
+		if n.Ordered {
+			tag = "ol"
+		}
+		r.WriteString("<" + tag)
+		if n.Ordered && n.Start != 1 {
+			r.WriteString(` start="` + strconv.Itoa(n.Start) + `"`)
+		}
+		r.WriteString(">\n")
+		r.renderChildren(n)
+		r.WriteString("</" + tag + ">\n")
+
+	case *markdown.Task:
+		id := escape(r.ids[n])
+		r.WriteString(`<li class="task-list-item" data-node-id="` + id + `" data-status="` + escape(n.Status.String()) + `"` + blockIDAttr(n) + ">")
+		loose := looseItem(n, n.Content)
+		if loose {
+			r.WriteString("<p>")
+		}
+		r.WriteString(`<input type="checkbox" data-node-id="` + id + `"`)
+		if n.Checked() {
+			r.WriteString(" checked")
+		}
+		r.WriteString("> ")
+		r.renderContent(n, n.Content)
+		if loose {
+			r.WriteString("</p>")
+		}
+		r.renderItemChildren(n)
+		r.WriteString("</li>\n")
+
+	case *markdown.ListItem:
+		r.WriteString("<li" + blockIDAttr(n) + ">")
+		if looseItem(n, n.Content) {
+			r.WriteString("<p>")
+			r.renderContent(n, n.Content)
+			r.WriteString("</p>")
+		} else {
+			r.renderContent(n, n.Content)
+		}
+		r.renderItemChildren(n)
+		r.WriteString("</li>\n")
+
+	case *markdown.CodeBlock:
+		r.WriteString("<pre><code")
+		if n.Language != "" {
+			r.WriteString(` class="language-` + escape(n.Language) + `"`)
+		}
+		r.WriteString(">")
+		if n.Content != "" {
+			r.WriteString(escape(n.Content) + "\n")
+		}
+		r.WriteString("</code></pre>\n")
+
+	case *markdown.Blockquote:
+		r.WriteString("<blockquote>\n")
+		r.renderChildren(n)
+		r.WriteString("</blockquote>\n")
+
+	case *markdown.Callout:
+		r.renderCallout(n)
+
+	case *markdown.ThematicBreak:
+		r.WriteString("<hr>\n")
+
+	case *markdown.RawBlock:
+		// Definitions are not displayed; other raw blocks are shown as
+		// written rather than interpreted
+		if n.Kind != markdown.RawLinkReference {
+			r.WriteString(`<pre class="raw-block">` + escape(n.Content) + "</pre>\n")
+		}
+
+	case *markdown.Table:
+		r.renderTable(n)
+
+	case *markdown.Text:
+		r.WriteString("<p>" + escape(n.Content) + "</p>\n")
+
+	default:
+		r.renderChildren(n)
+	}
+}
+
+// renderCallout renders a callout as a titled box. Foldable callouts are
+// details elements, open if they start expanded.
+func (r *renderer) renderCallout(callout *markdown.Callout) {
+	kind := escape(strings.ToLower(callout.Kind))
+	tag, titleTag := "div", "div"
+	if callout.Fold != markdown.FoldNone {
+		tag, titleTag = "details", "summary"
+	}
+	r.WriteString("<" + tag + ` class="callout" data-callout="` + kind + `"`)
+	if callout.Fold == markdown.FoldExpanded {
+		r.WriteString(" open")
+	}
+	r.WriteString(">\n<" + titleTag + ` class="callout-title">`)
+	if callout.Title != "" {
+		r.renderContent(callout, callout.Title)
+	} else {
+		// The default title is the kind, capitalised
+		if title := []rune(strings.ToLower(callout.Kind)); len(title) > 0 {
+			r.WriteString(escape(strings.ToUpper(string(title[:1])) + string(title[1:])))
+		}
+	}
+	r.WriteString("</" + titleTag + ">\n")
+	if len(callout.Children()) > 0 {
+		r.WriteString(`<div class="callout-content">` + "\n")
+		r.renderChildren(callout)
+		r.WriteString("</div>\n")
+	}
+	r.WriteString("</" + tag + ">\n")
+}
+
+// renderItemChildren renders the blocks nested under a list item
+func (r *renderer) renderItemChildren(item markdown.Node) {
+	if len(item.Children()) == 0 {
+		return
+	}
+	r.WriteString("\n")
+	r.renderChildren(item)
+}
+
+// looseItem reports whether a list item's text is followed by paragraphs of
+// its own, in which case the text is rendered as a paragraph too
+func looseItem(item markdown.Node, content string) bool {
+	if content == "" {
+		return false
+	}
+	for _, child := range item.Children() {
+		if _, ok := child.(*markdown.Paragraph); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTable renders a table, putting header rows in its head
+func (r *renderer) renderTable(table *markdown.Table) {
+	r.WriteString("<table>\n")
+	section := ""
+	for _, row := range table.Rows() {
+		want := "tbody"
+		if row.Header {
+			want = "thead"
+		}
+		if want != section {
+			if section != "" {
+				r.WriteString("</" + section + ">\n")
+			}
+			section = want
+			r.WriteString("<" + section + ">\n")
+		}
+
+		cell := "td"
+		if row.Header {
+			cell = "th"
+		}
+		r.WriteString("<tr>\n")
+		for i, c := range row.Cells() {
+			r.WriteString("<" + cell)
+			if i < len(table.Alignments) && table.Alignments[i] != markdown.AlignNone {
+				r.WriteString(` style="text-align: ` + string(table.Alignments[i]) + `"`)
+			}
+			r.WriteString(">")
+			r.renderContent(c, c.Content)
+			r.WriteString("</" + cell + ">\n")
+		}
+		r.WriteString("</tr>\n")
+	}
+	if section != "" {
+		r.WriteString("</" + section + ">\n")
+	}
+	r.WriteString("</table>\n")
+}
+
+// renderContent renders the inline content of a block. Nodes built in code
+// may only have their raw text, which is then rendered as plain text.
+func (r *renderer) renderContent(node markdown.Node, content string) {
+	if container, ok := node.(markdown.InlineContainer); ok && len(container.InlineNodes()) > 0 {
+		r.renderInlines(container.InlineNodes())
+		return
+	}
+	r.renderText(content)
+}
+
+// renderInlines renders inline nodes
+func (r *renderer) renderInlines(nodes []markdown.Node) {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *markdown.Text:
+			r.renderText(n.Content)
+
+		case *markdown.Emphasis:
+			r.WriteString("<em>")
+			r.renderInlines(n.Children())
+			r.WriteString("</em>")
+
+		case *markdown.Strong:
+			r.WriteString("<strong>")
+			r.renderInlines(n.Children())
+			r.WriteString("</strong>")
+
+		case *markdown.CodeSpan:
+			r.WriteString("<code>" + escape(n.Content) + "</code>")
+
+		case *markdown.Link:
+			r.WriteString("<a")
+			if safeURL(n.Destination) {
+				r.WriteString(` href="` + escape(n.Destination) + `"`)
+			}
+			if n.Title != "" {
+				r.WriteString(` title="` + escape(n.Title) + `"`)
+			}
+			r.WriteString(">")
+			if n.Autolink || len(n.Children()) == 0 {
+				r.WriteString(escape(n.Destination))
+			} else {
+				r.renderInlines(n.Children())
+			}
+			r.WriteString("</a>")
+
+		case *markdown.Image:
+			r.WriteString("<img")
+			if safeURL(n.Destination) {
+				r.WriteString(` src="` + escape(n.Destination) + `"`)
+			}
+			r.WriteString(` alt="` + escape(markdown.PlainText(n.Children())) + `"`)
+			if n.Title != "" {
+				r.WriteString(` title="` + escape(n.Title) + `"`)
+			}
+			r.WriteString(">")
+
+		case *markdown.WikiLink:
+			class := "wikilink"
+			if n.Embed {
+				class += " embed"
+			}
+			text := n.Alias
+			if text == "" {
+				text = n.Destination()
+			}
+			r.WriteString(`<a class="` + class + `" data-target="` + escape(n.Destination()) + `">` + escape(text) + "</a>")
+
+		case *markdown.Tag:
+			r.WriteString(`<span class="tag" data-tag="` + escape(n.Name) + `">#` + escape(n.Name) + "</span>")
+
+		default:
+			r.renderInlines(n.Children())
+		}
+	}
+}
+
+// renderText renders plain text, turning hard line breaks (a line ending in
+// two spaces or a backslash) into <br> elements
+func (r *renderer) renderText(text string) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i < len(lines)-1 {
+			if trimmed, ok := strings.CutSuffix(line, "\\"); ok {
+				r.WriteString(sourceText(trimmed) + "<br>\n")
+				continue
+			}
+			if strings.HasSuffix(line, "  ") {
+				r.WriteString(sourceText(strings.TrimRight(line, " ")) + "<br>\n")
+				continue
+			}
+			r.WriteString(sourceText(line) + "\n")
+			continue
+		}
+		r.WriteString(sourceText(line))
+	}
+}
+
+// sourceText escapes text as written in markdown, resolving its backslash
+// escapes and entity references first so they are not shown literally
+func sourceText(text string) string {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	gmhtml.DefaultWriter.Write(w, []byte(text))
+	w.Flush()
+	return b.String()
+}
+
+// blockIDAttr returns the data-block-id attribute for a block with a block
+// reference ID, so links to the block can be followed
+func blockIDAttr(node markdown.Node) string {
+	if id := markdown.BlockID(node); id != "" {
+		return ` data-block-id="` + escape(id) + `"`
+	}
+	return ""
+}
+
+// headingIDAttr returns the id attribute for a heading with an explicit ID,
+// so links to "#id" reach it
+func headingIDAttr(heading *markdown.Heading) string {
+	if heading.ID != "" {
+		return ` id="` + escape(heading.ID) + `"`
+	}
+	return ""
+}
+
+// NodeID returns an identifier for a block that depends on the block
+// itself rather than its position, so it survives other blocks being
+// inserted, removed or reordered, and parsing the same markdown again gives
+// the same IDs. A block with a block reference ID uses it, such as "^gym".
+// Others use their type and a hash of their text, such as "task-4f1d2a9c",
+// with "-2", "-3"... added for later blocks of the same type and text.
+// Blocks without text of their own, such as lists, are numbered by type.
+//
+// An ID is invalidated when the block's text or block ID is edited; a
+// task's status and metadata can change. Blocks sharing an ID before the
+// number swap numbers when they are reordered, and blocks without text
+// when others of their type are added or removed before them.
+func NodeID(node markdown.Node) string {
+	return nodeIDs(treeRoot(node))[node]
+}
+
+// FindNode returns the node below root with the given ID, or nil if there
+// is none. The empty ID is root itself.
+func FindNode(root markdown.Node, id string) markdown.Node {
+	if id == "" {
+		return root
+	}
+	for node, nodeID := range nodeIDs(treeRoot(root)) {
+		if nodeID == id && isBelow(node, root) {
+			return node
+		}
+	}
+	return nil
+}
+
+// nodeIDs returns the ID of every block below root
+func nodeIDs(root markdown.Node) map[markdown.Node]string {
+	ids := make(map[markdown.Node]string)
+	counts := make(map[string]int)
+	var assign func(parent markdown.Node)
+	assign = func(parent markdown.Node) {
+		for _, child := range parent.Children() {
+			id := baseID(child)
+			counts[id]++
+			if n := counts[id]; n > 1 {
+				id += "-" + strconv.Itoa(n)
+			}
+			ids[child] = id
+			assign(child)
+		}
+	}
+	assign(root)
+	return ids
+}
+
+// baseID returns a block's ID before blocks sharing it are numbered
+func baseID(node markdown.Node) string {
+	if id := markdown.BlockID(node); id != "" {
+		return "^" + id
+	}
+	var text string
+	switch n := node.(type) {
+	case *markdown.Task:
+		text = n.Description()
+	case *markdown.Heading:
+		text = n.Title
+	case *markdown.ListItem:
+		text = n.Content
+	case *markdown.Paragraph:
+		text = n.Content
+	case *markdown.CodeBlock:
+		text = n.Content
+	case *markdown.RawBlock:
+		text = n.Content
+	}
+	if text == "" {
+		return string(node.Type())
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(text))
+	return fmt.Sprintf("%s-%08x", node.Type(), hash.Sum32())
+}
+
+// treeRoot returns the top of the tree holding node
+func treeRoot(node markdown.Node) markdown.Node {
+	for node.Parent() != nil {
+		node = node.Parent()
+	}
+	return node
+}
+
+// isBelow reports whether node is a descendant of ancestor
+func isBelow(node, ancestor markdown.Node) bool {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if parent == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// safeURL reports whether a link destination is safe to follow: relative,
+// or using the http, https or mailto scheme
+func safeURL(destination string) bool {
+	// Browsers ignore whitespace and control characters inside a scheme
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, destination)
+	scheme, _, found := strings.Cut(cleaned, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// isItem reports whether a node is a list item
+func isItem(node markdown.Node) bool {
+	switch node.(type) {
+	case *markdown.Task, *markdown.ListItem:
+		return true
+	}
+	return false
+}
+
+// isBlock reports whether a node is a block rather than inline content
+func isBlock(node markdown.Node) bool {
+	switch node.(type) {
+	case *markdown.Text, *markdown.Emphasis, *markdown.Strong, *markdown.CodeSpan,
+		*markdown.Link, *markdown.Image, *markdown.WikiLink, *markdown.Tag:
+		return false
+	}
+	return true
+}
+
+var escaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+// escape escapes text for use in HTML content and attribute values
+func escape(text string) string {
+	return escaper.Replace(text)
+}
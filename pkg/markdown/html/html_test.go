@@ -0,0 +1,155 @@
+package html
+
+import (
+	"testing"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+	"github.com/notedownorg/planner/pkg/markdown/reader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "Headings and paragraphs",
+			content:  "# Week 42\n\nA *quiet* week with **lots** of `code`.\n\n## Notes\n\nFirst line  \nSecond line",
+			expected: "<h1>Week 42</h1>\n<p>A <em>quiet</em> week with <strong>lots</strong> of <code>code</code>.</p>\n<h2>Notes</h2>\n<p>First line<br>\nSecond line</p>\n",
+		},
+		{
+			name:    "Tasks",
+			content: "## Habits\n\n- [x] Gym\n- [ ] Read\n- [/] Journal",
+			expected: "<h2>Habits</h2>\n<ul>\n" +
+				`<li class="task-list-item" data-node-id="task-9dddcf20" data-status="x"><input type="checkbox" data-node-id="task-9dddcf20" checked> Gym</li>` + "\n" +
+				`<li class="task-list-item" data-node-id="task-4f0befe5" data-status=" "><input type="checkbox" data-node-id="task-4f0befe5"> Read</li>` + "\n" +
+				`<li class="task-list-item" data-node-id="task-2edb7f18" data-status="/"><input type="checkbox" data-node-id="task-2edb7f18"> Journal</li>` + "\n" +
+				"</ul>\n",
+		},
+		{
+			name:     "Nested ordered list",
+			content:  "3. Plan\n4. Build\n   - Design\n   - Code",
+			expected: "<ol start=\"3\">\n<li>Plan</li>\n<li>Build\n<ul>\n<li>Design</li>\n<li>Code</li>\n</ul>\n</li>\n</ol>\n",
+		},
+		{
+			name:     "Loose list items",
+			content:  "- Plan trip\n\n  Book a hotel\n- Pack",
+			expected: "<ul>\n<li><p>Plan trip</p>\n<p>Book a hotel</p>\n</li>\n<li>Pack</li>\n</ul>\n",
+		},
+		{
+			name:     "Links, wiki links and tags",
+			content:  "See [the docs](https://example.com \"Docs\"), [[Week 41#Habits|last week]] and #health/sleep",
+			expected: "<p>See <a href=\"https://example.com\" title=\"Docs\">the docs</a>, <a class=\"wikilink\" data-target=\"Week 41#Habits\">last week</a> and <span class=\"tag\" data-tag=\"health/sleep\">#health/sleep</span></p>\n",
+		},
+		{
+			name:     "Heading IDs",
+			content:  "Habits {#habits}\n======\n\n## Notes",
+			expected: "<h1 id=\"habits\">Habits</h1>\n<h2>Notes</h2>\n",
+		},
+		{
+			name:     "Code block",
+			content:  "```go\nif a < b {\n}\n```",
+			expected: "<pre><code class=\"language-go\">if a &lt; b {\n}\n</code></pre>\n",
+		},
+		{
+			name:     "Blockquote and thematic break",
+			content:  "> Quoted\n\n---",
+			expected: "<blockquote>\n<p>Quoted</p>\n</blockquote>\n<hr>\n",
+		},
+		{
+			name:    "Callouts",
+			content: "> [!tip] Keep *going*\n> Nearly there\n\n> [!warning]-\n> Sleep slipped",
+			expected: "<div class=\"callout\" data-callout=\"tip\">\n<div class=\"callout-title\">Keep <em>going</em></div>\n<div class=\"callout-content\">\n<p>Nearly there</p>\n</div>\n</div>\n" +
+				"<details class=\"callout\" data-callout=\"warning\">\n<summary class=\"callout-title\">Warning</summary>\n<div class=\"callout-content\">\n<p>Sleep slipped</p>\n</div>\n</details>\n",
+		},
+		{
+			name:     "Table",
+			content:  "| Habit | Days |\n| :--- | ---: |\n| Gym | 3 |",
+			expected: "<table>\n<thead>\n<tr>\n<th style=\"text-align: left\">Habit</th>\n<th style=\"text-align: right\">Days</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td style=\"text-align: left\">Gym</td>\n<td style=\"text-align: right\">3</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:     "Raw HTML is escaped",
+			content:  "Hello <script>alert(1)</script>",
+			expected: "<p>Hello &lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		},
+		{
+			name:     "Entity references",
+			content:  "AT&amp;T &copy; &#35;1",
+			expected: "<p>AT&amp;T © #1</p>\n",
+		},
+		{
+			name:     "Backslash escapes",
+			content:  "\\*not em\\* and \\<b\\>",
+			expected: "<p>*not em* and &lt;b&gt;</p>\n",
+		},
+		{
+			name:     "Unsafe links are dropped",
+			content:  "[click](javascript:alert(1)) ![img](JaVaScRiPt:alert(1))",
+			expected: "<p><a>click</a> <img alt=\"img\"></p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := reader.ParseMarkdown(tt.content)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, Render(doc))
+		})
+	}
+}
+
+func TestRenderBuiltTree(t *testing.T) {
+	doc := markdown.NewDocument()
+	heading := markdown.NewHeading(2, "Habits")
+	heading.AddChild(markdown.NewTask(true, "Gym & <swim>"))
+	heading.AddChild(markdown.NewTask(false, "Read"))
+	doc.AddChild(heading)
+
+	// Tasks outside a list are still rendered as one
+	assert.Equal(t, "<h2>Habits</h2>\n<ul>\n"+
+		`<li class="task-list-item" data-node-id="task-6900a004" data-status="x"><input type="checkbox" data-node-id="task-6900a004" checked> Gym &amp; &lt;swim&gt;</li>`+"\n"+
+		`<li class="task-list-item" data-node-id="task-4f0befe5" data-status=" "><input type="checkbox" data-node-id="task-4f0befe5"> Read</li>`+"\n"+
+		"</ul>\n", Render(doc))
+}
+
+func TestNodeID(t *testing.T) {
+	content := "# Week 42\n\n## Habits\n\n- [ ] Gym\n  - [ ] Stretch\n- [ ] Read ^read\n- [ ] Stretch"
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	tasks := markdown.FindAllTasks(doc)
+	stretch, read, second := tasks[1], tasks[2], tasks[3]
+
+	id := NodeID(stretch)
+	assert.Equal(t, "task-03d3b9ca", id)
+	assert.Equal(t, "^read", NodeID(read))
+	assert.Equal(t, "task-03d3b9ca-2", NodeID(second))
+	assert.Same(t, stretch, FindNode(doc, id))
+	assert.Same(t, read, FindNode(doc, "^read"))
+	assert.Same(t, second, FindNode(doc, "task-03d3b9ca-2"))
+	assert.Same(t, doc, FindNode(doc, ""))
+
+	// Parsing the same markdown again gives the same IDs
+	again, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+	assert.Equal(t, "Stretch", FindNode(again, id).(*markdown.Task).Content)
+
+	// IDs survive status changes and other blocks moving around them
+	stretch.SetChecked(true)
+	list := read.Parent()
+	list.RemoveChild(read)
+	list.InsertChild(0, read)
+	list.AddChild(markdown.NewTask(false, "Journal"))
+	assert.Equal(t, id, NodeID(stretch))
+	assert.Equal(t, "^read", NodeID(read))
+	assert.Same(t, stretch, FindNode(doc, id))
+
+	// Only nodes below the given root are found
+	assert.Same(t, stretch, FindNode(tasks[0], id))
+	assert.Nil(t, FindNode(read, id))
+
+	assert.Nil(t, FindNode(doc, "task-00000000"))
+	assert.Nil(t, FindNode(doc, "0.0"))
+}
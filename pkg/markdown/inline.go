@@ -0,0 +1,279 @@
+package markdown
+
+import "strings"
+
+// InlineContainer is implemented by blocks whose text is made up of inline
+// nodes. The inline nodes are kept apart from the block's children, which
+// hold nested blocks (a heading's section, a list item's sub-list).
+type InlineContainer interface {
+	Node
+	InlineNodes() []Node
+	SetInlines(nodes ...Node)
+}
+
+// setInlines attaches inline nodes to their block
+func setInlines(parent Node, nodes []Node) []Node {
+	inlines := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		node.SetParent(parent)
+		inlines = append(inlines, node)
+	}
+	return inlines
+}
+
+func (h *Heading) InlineNodes() []Node        { return h.Inlines }
+func (h *Heading) SetInlines(nodes ...Node)   { h.Inlines = setInlines(h, nodes) }
+func (p *Paragraph) InlineNodes() []Node      { return p.Inlines }
+func (p *Paragraph) SetInlines(nodes ...Node) { p.Inlines = setInlines(p, nodes) }
+func (t *Task) InlineNodes() []Node           { return t.Inlines }
+func (t *Task) SetInlines(nodes ...Node)      { t.Inlines = setInlines(t, nodes) }
+func (li *ListItem) InlineNodes() []Node      { return li.Inlines }
+func (li *ListItem) SetInlines(nodes ...Node) { li.Inlines = setInlines(li, nodes) }
+func (c *Callout) InlineNodes() []Node        { return c.Inlines }
+func (c *Callout) SetInlines(nodes ...Node)   { c.Inlines = setInlines(c, nodes) }
+
+// Emphasis represents emphasised text, delimited by * or _
+type Emphasis struct {
+	BaseNode
+	Delimiter string
+}
+
+func NewEmphasis(delimiter string, children ...Node) *Emphasis {
+	e := &Emphasis{
+		BaseNode: BaseNode{
+			NodeType: NodeEmphasis,
+			children: []Node{},
+		},
+		Delimiter: delimiter,
+	}
+	e.init(e)
+	for _, child := range children {
+		e.AddChild(child)
+	}
+	return e
+}
+
+// Strong represents strongly emphasised text, delimited by ** or __
+type Strong struct {
+	BaseNode
+	Delimiter string
+}
+
+func NewStrong(delimiter string, children ...Node) *Strong {
+	s := &Strong{
+		BaseNode: BaseNode{
+			NodeType: NodeStrong,
+			children: []Node{},
+		},
+		Delimiter: delimiter,
+	}
+	s.init(s)
+	for _, child := range children {
+		s.AddChild(child)
+	}
+	return s
+}
+
+// CodeSpan represents inline code. Content is the raw text between the
+// backtick delimiters, including any padding spaces.
+type CodeSpan struct {
+	BaseNode
+	Delimiter string
+	Content   string
+}
+
+func NewCodeSpan(content string) *CodeSpan {
+	delimiter := "`"
+	for strings.Contains(content, delimiter) {
+		delimiter += "`"
+	}
+	c := &CodeSpan{
+		BaseNode: BaseNode{
+			NodeType: NodeCodeSpan,
+			children: []Node{},
+		},
+		Delimiter: delimiter,
+		Content:   content,
+	}
+	c.init(c)
+	return c
+}
+
+// Link represents a link; its children are the link text. Autolinks such as
+// <https://example.com> have no children.
+type Link struct {
+	BaseNode
+	Destination string
+	Title       string
+	Autolink    bool
+}
+
+func NewLink(destination, title string, children ...Node) *Link {
+	l := &Link{
+		BaseNode: BaseNode{
+			NodeType: NodeLink,
+			children: []Node{},
+		},
+		Destination: destination,
+		Title:       title,
+	}
+	l.init(l)
+	for _, child := range children {
+		l.AddChild(child)
+	}
+	return l
+}
+
+// Image represents an image; its children are the alt text
+type Image struct {
+	BaseNode
+	Destination string
+	Title       string
+}
+
+func NewImage(destination, title string, children ...Node) *Image {
+	i := &Image{
+		BaseNode: BaseNode{
+			NodeType: NodeImage,
+			children: []Node{},
+		},
+		Destination: destination,
+		Title:       title,
+	}
+	i.init(i)
+	for _, child := range children {
+		i.AddChild(child)
+	}
+	return i
+}
+
+// WikiLink represents an Obsidian style [[Target#Heading|Alias]] link, or an
+// ![[Target]] embed. Target is empty for links within the same note, and a
+// link to a block ([[Target#^id]]) sets Block instead of Heading.
+type WikiLink struct {
+	BaseNode
+	Target  string
+	Heading string
+	Block   string
+	Alias   string
+	Embed   bool
+}
+
+// NewWikiLink creates a wiki link. The target may include a "#Heading" or
+// "#^block" suffix.
+func NewWikiLink(target, alias string) *WikiLink {
+	w := &WikiLink{
+		BaseNode: BaseNode{
+			NodeType: NodeWikiLink,
+			children: []Node{},
+		},
+		Alias: alias,
+	}
+	w.Target, w.Heading, w.Block = splitLinkTarget(target)
+	w.init(w)
+	return w
+}
+
+// Destination returns the link target as written inside the brackets,
+// without the alias
+func (w *WikiLink) Destination() string {
+	switch {
+	case w.Block != "":
+		return w.Target + "#^" + w.Block
+	case w.Heading != "":
+		return w.Target + "#" + w.Heading
+	}
+	return w.Target
+}
+
+// splitLinkTarget splits a wiki link target into its note, heading and
+// block parts
+func splitLinkTarget(target string) (note, heading, block string) {
+	note, section, _ := strings.Cut(target, "#")
+	if id, ok := strings.CutPrefix(section, "^"); ok {
+		return note, "", id
+	}
+	return note, section, ""
+}
+
+// Tag represents a #tag. Name excludes the leading "#" and may contain "/"
+// to nest tags, as in #project/planner.
+type Tag struct {
+	BaseNode
+	Name string
+}
+
+func NewTag(name string) *Tag {
+	t := &Tag{
+		BaseNode: BaseNode{
+			NodeType: NodeTag,
+			children: []Node{},
+		},
+		Name: strings.TrimPrefix(name, "#"),
+	}
+	t.init(t)
+	return t
+}
+
+// HasPrefix reports whether the tag is prefix or nested under it, so that
+// #project/planner matches "project"
+func (t *Tag) HasPrefix(prefix string) bool {
+	prefix = strings.TrimPrefix(prefix, "#")
+	return t.Name == prefix || strings.HasPrefix(t.Name, prefix+"/")
+}
+
+// FindLinks returns the wiki links within a node, searching the inline
+// content of every block beneath it
+func FindLinks(node Node) []*WikiLink {
+	var links []*WikiLink
+	Walk(node, func(n Node, entering bool) WalkStatus {
+		if link, ok := n.(*WikiLink); ok && entering {
+			links = append(links, link)
+		}
+		return WalkContinue
+	})
+	return links
+}
+
+// FindTags returns the tags within a node, searching the inline content of
+// every block beneath it
+func FindTags(node Node) []*Tag {
+	var tags []*Tag
+	Walk(node, func(n Node, entering bool) WalkStatus {
+		if tag, ok := n.(*Tag); ok && entering {
+			tags = append(tags, tag)
+		}
+		return WalkContinue
+	})
+	return tags
+}
+
+// PlainText returns the text of inline nodes with all markup removed
+func PlainText(nodes []Node) string {
+	var b strings.Builder
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Text:
+			b.WriteString(n.Content)
+		case *CodeSpan:
+			b.WriteString(strings.TrimSpace(n.Content))
+		case *WikiLink:
+			if n.Alias != "" {
+				b.WriteString(n.Alias)
+			} else {
+				b.WriteString(n.Destination())
+			}
+		case *Tag:
+			b.WriteString("#" + n.Name)
+		case *Link:
+			if n.Autolink {
+				b.WriteString(n.Destination)
+			} else {
+				b.WriteString(PlainText(n.Children()))
+			}
+		default:
+			b.WriteString(PlainText(n.Children()))
+		}
+	}
+	return b.String()
+}
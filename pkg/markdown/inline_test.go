@@ -0,0 +1,90 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetInlines(t *testing.T) {
+	para := NewParagraph("")
+	text := NewText("Read ")
+	link := NewWikiLink("Atomic Habits", "")
+	para.SetInlines(text, link)
+
+	assert.Len(t, para.InlineNodes(), 2)
+	assert.Empty(t, para.Children(), "inline nodes are not block children")
+	assert.Equal(t, para, text.Parent())
+	assert.Equal(t, para, link.Parent())
+}
+
+func TestNewCodeSpanDelimiter(t *testing.T) {
+	assert.Equal(t, "`", NewCodeSpan("plain").Delimiter)
+	assert.Equal(t, "``", NewCodeSpan("has ` tick").Delimiter)
+}
+
+func TestPlainText(t *testing.T) {
+	nodes := []Node{
+		NewStrong("**", NewText("Gym")),
+		NewText(" and "),
+		NewWikiLink("Atomic Habits", "the book"),
+		NewText(" "),
+		NewLink("https://example.com", "", NewText("site")),
+		NewText(" "),
+		NewCodeSpan(" x "),
+	}
+
+	assert.Equal(t, "Gym and the book site x", PlainText(nodes))
+}
+
+func TestNewWikiLinkTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		note    string
+		heading string
+		block   string
+	}{
+		{"Atomic Habits", "Atomic Habits", "", ""},
+		{"Atomic Habits#Chapter 1", "Atomic Habits", "Chapter 1", ""},
+		{"Atomic Habits#^quote", "Atomic Habits", "", "quote"},
+		{"#Habits", "", "Habits", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			link := NewWikiLink(tt.target, "")
+			assert.Equal(t, tt.note, link.Target)
+			assert.Equal(t, tt.heading, link.Heading)
+			assert.Equal(t, tt.block, link.Block)
+			assert.Equal(t, tt.target, link.Destination())
+		})
+	}
+}
+
+func TestFindLinksAndTags(t *testing.T) {
+	doc := NewDocument()
+	heading := NewHeading(1, "Week 42 #weekly")
+	heading.SetInlines(NewText("Week 42 "), NewTag("#weekly"))
+	doc.AddChild(heading)
+
+	task := NewTask(false, "Read [[Atomic Habits]] #project/reading")
+	task.SetInlines(NewText("Read "), NewWikiLink("Atomic Habits", ""), NewText(" "), NewTag("project/reading"))
+	heading.AddChild(task)
+
+	para := NewParagraph("See **[[Notes#Ideas|ideas]]**")
+	para.SetInlines(NewText("See "), NewStrong("**", NewWikiLink("Notes#Ideas", "ideas")))
+	heading.AddChild(para)
+
+	links := FindLinks(doc)
+	assert.Len(t, links, 2)
+	assert.Equal(t, "Atomic Habits", links[0].Target)
+	assert.Equal(t, "Ideas", links[1].Heading)
+	assert.Len(t, FindLinks(task), 1)
+
+	tags := FindTags(doc)
+	assert.Len(t, tags, 2)
+	assert.Equal(t, "weekly", tags[0].Name)
+	assert.True(t, tags[1].HasPrefix("project"))
+	assert.True(t, tags[1].HasPrefix("#project/reading"))
+	assert.False(t, tags[1].HasPrefix("proj"))
+}
@@ -0,0 +1,532 @@
+package markdown
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonNode is the JSON form of a node. Type says which kind of node it is,
+// and fields that do not apply to that kind are omitted.
+//
+// Nodes parsed from source carry their spans, and the document carries the
+// source itself, so a tree read back from JSON is still written out
+// verbatim where it has not changed. Unmodified nodes also carry a snapshot
+// of their fields and the shape of their children, and a decoded node whose
+// snapshot no longer matches is marked modified, so edits made in JSON are
+// written without the client having to flag them.
+type jsonNode struct {
+	Type NodeType `json:"type"`
+
+	// Document
+	Source       string    `json:"source,omitempty"`
+	FrontMatter  *jsonNode `json:"front_matter,omitempty"`
+	LineEnding   string    `json:"line_ending,omitempty"`
+	MixedEndings []int     `json:"mixed_line_endings,omitempty"`
+	BOM          bool      `json:"bom,omitempty"`
+	Trailing     string    `json:"trailing,omitempty"`
+
+	// Front matter: YAML keeps key order and formatting, Fields is the
+	// decoded values for convenience
+	YAML   string         `json:"yaml,omitempty"`
+	Fields map[string]any `json:"fields,omitempty"`
+
+	Level       int         `json:"level,omitempty"`
+	Setext      bool        `json:"setext,omitempty"`
+	ID          string      `json:"id,omitempty"`
+	Attributes  string      `json:"attributes,omitempty"`
+	AutoID      string      `json:"auto_id,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Status      string      `json:"status,omitempty"`
+	Content     string      `json:"content,omitempty"`
+	Ordered     bool        `json:"ordered,omitempty"`
+	Start       int         `json:"start,omitempty"`
+	Marker      string      `json:"marker,omitempty"`
+	Language    string      `json:"language,omitempty"`
+	Info        string      `json:"info,omitempty"`
+	Fenced      bool        `json:"fenced,omitempty"`
+	Fence       string      `json:"fence,omitempty"`
+	Alignments  []Alignment `json:"alignments,omitempty"`
+	Header      bool        `json:"header,omitempty"`
+	Delimiter   string      `json:"delimiter,omitempty"`
+	Destination string      `json:"destination,omitempty"`
+	Autolink    bool        `json:"autolink,omitempty"`
+	Target      string      `json:"target,omitempty"`
+	Heading     string      `json:"heading,omitempty"`
+	Block       string      `json:"block,omitempty"`
+	Alias       string      `json:"alias,omitempty"`
+	Embed       bool        `json:"embed,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Kind        string      `json:"kind,omitempty"`
+	Fold        CalloutFold `json:"fold,omitempty"`
+	BlockID     string      `json:"block_id,omitempty"`
+
+	// Task metadata, decoded from Content; a client may edit it instead of
+	// the text, and the text is updated to match
+	Metadata *jsonMetadata `json:"metadata,omitempty"`
+
+	Inlines  []*jsonNode `json:"inlines,omitempty"`
+	Children []*jsonNode `json:"children,omitempty"`
+
+	Span     *Span  `json:"span,omitempty"`
+	OwnSpan  *Span  `json:"own_span,omitempty"`
+	Range    *Range `json:"range,omitempty"`
+	Modified bool   `json:"modified,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// jsonMetadata is the JSON form of a task's metadata, with dates in
+// DateFormat and absent values omitted
+type jsonMetadata struct {
+	Due        string            `json:"due,omitempty"`
+	Scheduled  string            `json:"scheduled,omitempty"`
+	Start      string            `json:"start,omitempty"`
+	Created    string            `json:"created,omitempty"`
+	Completed  string            `json:"completed,omitempty"`
+	Cancelled  string            `json:"cancelled,omitempty"`
+	Priority   Priority          `json:"priority,omitempty"`
+	Recurrence string            `json:"recurrence,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+}
+
+// jsonShape identifies a child in its parent's snapshot
+type jsonShape struct {
+	Type NodeType `json:"type"`
+	Span *Span    `json:"span,omitempty"`
+}
+
+// MarshalNode encodes a node and its descendants as JSON
+func MarshalNode(node Node) ([]byte, error) {
+	j, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalNode decodes a node and its descendants from JSON written by
+// MarshalNode
+func UnmarshalNode(data []byte) (Node, error) {
+	var j jsonNode
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return decodeNode(&j, nil)
+}
+
+// MarshalJSON encodes the node with a "type" discriminator, its fields and
+// its children. Every node type gets this method through BaseNode.
+func (n *BaseNode) MarshalJSON() ([]byte, error) {
+	return MarshalNode(n.node())
+}
+
+// UnmarshalJSON decodes a document written by MarshalJSON
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var j jsonNode
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Type != NodeDocument {
+		return fmt.Errorf("expected a %s node, got %q", NodeDocument, j.Type)
+	}
+	*d = Document{BaseNode: BaseNode{NodeType: NodeDocument}}
+	d.init(d)
+	_, err := decodeNode(&j, d)
+	return err
+}
+
+// encodeNode converts a node and its descendants to their JSON form
+func encodeNode(node Node) (*jsonNode, error) {
+	j := &jsonNode{Type: node.Type(), Modified: node.Modified()}
+
+	switch n := node.(type) {
+	case *Document:
+		j.Source = string(n.source)
+		j.LineEnding, j.MixedEndings = n.LineEnding, n.mixed
+		j.BOM, j.Trailing = n.BOM, n.Trailing
+		if n.FrontMatter != nil {
+			fm, err := encodeNode(n.FrontMatter)
+			if err != nil {
+				return nil, err
+			}
+			j.FrontMatter = fm
+		}
+	case *FrontMatter:
+		yaml, err := n.YAML()
+		if err != nil {
+			return nil, err
+		}
+		j.YAML = yaml
+		j.Fields = n.Fields
+	case *Heading:
+		j.Level, j.Title, j.Setext = n.Level, n.Title, n.Setext
+		j.ID, j.Attributes, j.AutoID = n.ID, n.Attributes, n.AutoID
+	case *Paragraph:
+		j.Content, j.BlockID = n.Content, n.BlockID
+	case *Task:
+		j.Status, j.Content, j.BlockID = n.Status.String(), n.Content, n.BlockID
+		j.Metadata = encodeMetadata(n.Metadata)
+	case *List:
+		j.Ordered, j.Start, j.Marker = n.Ordered, n.Start, n.Marker
+	case *ListItem:
+		j.Content, j.BlockID = n.Content, n.BlockID
+	case *Text:
+		j.Content = n.Content
+	case *CodeBlock:
+		j.Language, j.Info, j.Content = n.Language, n.Info, n.Content
+		j.Fenced, j.Fence = n.Fenced, n.Fence
+	case *Blockquote:
+	case *Callout:
+		j.Kind, j.Title, j.Fold = n.Kind, n.Title, n.Fold
+	case *ThematicBreak:
+		j.Marker = n.Marker
+	case *RawBlock:
+		j.Kind, j.Content = n.Kind, n.Content
+	case *Table:
+		j.Alignments = n.Alignments
+	case *TableRow:
+		j.Header = n.Header
+	case *TableCell:
+		j.Content = n.Content
+	case *Emphasis:
+		j.Delimiter = n.Delimiter
+	case *Strong:
+		j.Delimiter = n.Delimiter
+	case *CodeSpan:
+		j.Delimiter, j.Content = n.Delimiter, n.Content
+	case *Link:
+		j.Destination, j.Title, j.Autolink = n.Destination, n.Title, n.Autolink
+	case *Image:
+		j.Destination, j.Title = n.Destination, n.Title
+	case *WikiLink:
+		j.Target, j.Heading, j.Block = n.Target, n.Heading, n.Block
+		j.Alias, j.Embed = n.Alias, n.Embed
+	case *Tag:
+		j.Name = n.Name
+	default:
+		return nil, fmt.Errorf("cannot encode %s node", node.Type())
+	}
+
+	if container, ok := node.(InlineContainer); ok {
+		for _, inline := range container.InlineNodes() {
+			encoded, err := encodeNode(inline)
+			if err != nil {
+				return nil, err
+			}
+			j.Inlines = append(j.Inlines, encoded)
+		}
+	}
+	for _, child := range node.Children() {
+		encoded, err := encodeNode(child)
+		if err != nil {
+			return nil, err
+		}
+		j.Children = append(j.Children, encoded)
+	}
+
+	if span, ok := node.Span(); ok {
+		j.Span = &span
+	}
+	if span, ok := node.OwnSpan(); ok {
+		j.OwnSpan = &span
+	}
+	if r, ok := node.Range(); ok {
+		j.Range = &r
+	}
+	if !j.Modified {
+		j.Snapshot = snapshot(j)
+	}
+	return j, nil
+}
+
+// decodeNode builds a node and its descendants from their JSON form. The
+// fields are decoded into node if it is given, or into a new node of the
+// JSON's type.
+func decodeNode(j *jsonNode, node Node) (Node, error) {
+	if node == nil {
+		var err error
+		if node, err = newNode(j); err != nil {
+			return nil, err
+		}
+	}
+
+	switch n := node.(type) {
+	case *Document:
+		if j.Source != "" {
+			n.source = []byte(j.Source)
+		}
+		n.LineEnding, n.BOM, n.Trailing = j.LineEnding, j.BOM, j.Trailing
+		n.SetMixedLineEndings(j.MixedEndings)
+		if j.FrontMatter != nil {
+			fm, err := decodeNode(j.FrontMatter, nil)
+			if err != nil {
+				return nil, err
+			}
+			frontMatter, ok := fm.(*FrontMatter)
+			if !ok {
+				return nil, fmt.Errorf("front matter must be a %s node, got %q", NodeFrontMatter, fm.Type())
+			}
+			n.FrontMatter = frontMatter
+		}
+	case *FrontMatter:
+		// Built by newNode from the YAML or fields
+	case *Heading:
+		n.Level, n.Title, n.Setext = j.Level, j.Title, j.Setext
+		n.ID, n.Attributes, n.AutoID = j.ID, j.Attributes, j.AutoID
+	case *Paragraph:
+		n.Content, n.BlockID = j.Content, j.BlockID
+	case *Task:
+		status, _ := utf8.DecodeRuneInString(j.Status)
+		if status == utf8.RuneError {
+			status = rune(StatusTodo)
+		}
+		n.Status, n.Content, n.BlockID = TaskStatus(status), j.Content, j.BlockID
+		n.Metadata = ParseTaskMetadata(n.Content)
+		if j.Metadata != nil {
+			meta, err := decodeMetadata(j.Metadata)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(encodeMetadata(meta), encodeMetadata(n.Metadata)) {
+				n.SetMetadata(meta)
+			}
+		}
+	case *List:
+		n.Ordered, n.Start, n.Marker = j.Ordered, j.Start, j.Marker
+	case *ListItem:
+		n.Content, n.BlockID = j.Content, j.BlockID
+	case *Text:
+		n.Content = j.Content
+	case *CodeBlock:
+		n.Language, n.Info, n.Content = j.Language, j.Info, j.Content
+		n.Fenced, n.Fence = j.Fenced, j.Fence
+	case *Blockquote:
+	case *Callout:
+		n.Kind, n.Title, n.Fold = j.Kind, j.Title, j.Fold
+	case *ThematicBreak:
+		n.Marker = j.Marker
+	case *RawBlock:
+		n.Kind, n.Content = j.Kind, j.Content
+	case *Table:
+		n.Alignments = j.Alignments
+	case *TableRow:
+		n.Header = j.Header
+	case *TableCell:
+		n.Content = j.Content
+	case *Emphasis:
+		n.Delimiter = j.Delimiter
+	case *Strong:
+		n.Delimiter = j.Delimiter
+	case *CodeSpan:
+		n.Delimiter, n.Content = j.Delimiter, j.Content
+	case *Link:
+		n.Destination, n.Title, n.Autolink = j.Destination, j.Title, j.Autolink
+	case *Image:
+		n.Destination, n.Title = j.Destination, j.Title
+	case *WikiLink:
+		n.Target, n.Heading, n.Block = j.Target, j.Heading, j.Block
+		n.Alias, n.Embed = j.Alias, j.Embed
+	case *Tag:
+		n.Name = j.Name
+	}
+
+	if len(j.Inlines) > 0 {
+		container, ok := node.(InlineContainer)
+		if !ok {
+			return nil, fmt.Errorf("%s node cannot have inlines", node.Type())
+		}
+		var inlines []Node
+		for _, inline := range j.Inlines {
+			decoded, err := decodeNode(inline, nil)
+			if err != nil {
+				return nil, err
+			}
+			inlines = append(inlines, decoded)
+		}
+		container.SetInlines(inlines...)
+	}
+	for _, child := range j.Children {
+		decoded, err := decodeNode(child, nil)
+		if err != nil {
+			return nil, err
+		}
+		node.AddChild(decoded)
+	}
+
+	if j.Span != nil {
+		node.SetSpan(*j.Span)
+	}
+	if j.OwnSpan != nil {
+		node.SetOwnSpan(*j.OwnSpan)
+	}
+	if j.Range != nil {
+		node.SetRange(*j.Range)
+	}
+	node.ResetModified()
+	if j.Modified || j.Snapshot != snapshot(j) {
+		node.MarkModified()
+	}
+	return node, nil
+}
+
+// snapshot fingerprints a node's own fields, the types and spans of its
+// children and the fields of its inlines. A child's own edits change its
+// snapshot rather than its parent's, but adding, removing or reordering
+// children changes the parent's.
+func snapshot(j *jsonNode) string {
+	fields := *j
+	fields.FrontMatter, fields.Inlines, fields.Children = nil, nil, nil
+	fields.Span, fields.OwnSpan, fields.Range = nil, nil, nil
+	fields.Modified, fields.Snapshot = false, ""
+
+	state := struct {
+		Fields      jsonNode    `json:"fields"`
+		FrontMatter *jsonShape  `json:"front_matter,omitempty"`
+		Inlines     []string    `json:"inlines,omitempty"`
+		Children    []jsonShape `json:"children,omitempty"`
+	}{Fields: fields}
+	if j.FrontMatter != nil {
+		state.FrontMatter = &jsonShape{Type: j.FrontMatter.Type, Span: j.FrontMatter.Span}
+	}
+	for _, inline := range j.Inlines {
+		state.Inlines = append(state.Inlines, snapshot(inline))
+	}
+	for _, child := range j.Children {
+		state.Children = append(state.Children, jsonShape{Type: child.Type, Span: child.Span})
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return ""
+	}
+	hash := fnv.New64a()
+	hash.Write(data)
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// encodeMetadata converts task metadata to its JSON form, or nil when the
+// task has none
+func encodeMetadata(meta TaskMetadata) *jsonMetadata {
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(DateFormat)
+	}
+	j := &jsonMetadata{
+		Due:        date(meta.Due),
+		Scheduled:  date(meta.Scheduled),
+		Start:      date(meta.Start),
+		Created:    date(meta.Created),
+		Completed:  date(meta.Completed),
+		Cancelled:  date(meta.Cancelled),
+		Priority:   meta.Priority,
+		Recurrence: meta.Recurrence,
+	}
+	if len(meta.Fields) > 0 {
+		j.Fields = meta.Fields
+	}
+	if reflect.DeepEqual(*j, jsonMetadata{}) {
+		return nil
+	}
+	return j
+}
+
+// decodeMetadata converts task metadata from its JSON form
+func decodeMetadata(j *jsonMetadata) (TaskMetadata, error) {
+	meta := TaskMetadata{Priority: j.Priority, Recurrence: j.Recurrence}
+	if len(j.Fields) > 0 {
+		meta.Fields = j.Fields
+	}
+	for _, date := range []struct {
+		name  string
+		value string
+		field *time.Time
+	}{
+		{"due", j.Due, &meta.Due},
+		{"scheduled", j.Scheduled, &meta.Scheduled},
+		{"start", j.Start, &meta.Start},
+		{"created", j.Created, &meta.Created},
+		{"completed", j.Completed, &meta.Completed},
+		{"cancelled", j.Cancelled, &meta.Cancelled},
+	} {
+		if date.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(DateFormat, date.value)
+		if err != nil {
+			return TaskMetadata{}, fmt.Errorf("invalid %s date %q", date.name, date.value)
+		}
+		*date.field = parsed
+	}
+	return meta, nil
+}
+
+// newNode creates an empty node of a JSON node's type
+func newNode(j *jsonNode) (Node, error) {
+	switch j.Type {
+	case NodeDocument:
+		return NewDocument(), nil
+	case NodeFrontMatter:
+		if j.YAML != "" {
+			return ParseFrontMatter(j.YAML)
+		}
+		fm := NewFrontMatter()
+		keys := make([]string, 0, len(j.Fields))
+		for key := range j.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fm.Set(key, j.Fields[key])
+		}
+		return fm, nil
+	case NodeHeading:
+		return NewHeading(0, ""), nil
+	case NodeParagraph:
+		return NewParagraph(""), nil
+	case NodeTask:
+		return NewTask(false, ""), nil
+	case NodeList:
+		return NewList(false), nil
+	case NodeListItem:
+		return NewListItem(""), nil
+	case NodeText:
+		return NewText(""), nil
+	case NodeCodeBlock:
+		return NewCodeBlock("", ""), nil
+	case NodeBlockquote:
+		return NewBlockquote(), nil
+	case NodeCallout:
+		return NewCallout("", ""), nil
+	case NodeThematicBreak:
+		return NewThematicBreak(""), nil
+	case NodeRawBlock:
+		return NewRawBlock("", ""), nil
+	case NodeTable:
+		return NewTable(), nil
+	case NodeTableRow:
+		return NewTableRow(false), nil
+	case NodeTableCell:
+		return NewTableCell(""), nil
+	case NodeEmphasis:
+		return NewEmphasis(""), nil
+	case NodeStrong:
+		return NewStrong(""), nil
+	case NodeCodeSpan:
+		return NewCodeSpan(""), nil
+	case NodeLink:
+		return NewLink("", ""), nil
+	case NodeImage:
+		return NewImage("", ""), nil
+	case NodeWikiLink:
+		return NewWikiLink("", ""), nil
+	case NodeTag:
+		return NewTag(""), nil
+	}
+	return nil, fmt.Errorf("unknown node type %q", j.Type)
+}
@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	doc := NewDocument()
+	doc.LineEnding, doc.BOM = CRLF, true
+	doc.FrontMatter = NewFrontMatter()
+	doc.FrontMatter.Set("week", 42)
+
+	heading := NewHeading(2, "Habits")
+	heading.SetInlines(NewText("Habits"))
+	heading.Setext, heading.ID, heading.Attributes = true, "habits", ".wide"
+	list := NewList(true)
+	list.Start, list.Marker = 3, ")"
+	gym := NewTaskWithStatus(StatusInProgress, "Gym 📅 2026-10-20")
+	gym.SetInlines(NewText("Gym "), NewStrong("**", NewText("now")), NewWikiLink("Week 41#^abc", "last"), NewTag("health"))
+	list.AddChild(gym)
+	heading.AddChild(list)
+	table := NewTable(AlignLeft, AlignRight)
+	table.AddChild(NewTableRow(true, "Habit", "Days"))
+	heading.AddChild(table)
+	heading.AddChild(NewCodeBlock("go", "fmt.Println()"))
+	callout := NewCallout("warning", "Sleep")
+	callout.Fold = FoldCollapsed
+	callout.AddChild(NewParagraph("Slipped"))
+	heading.AddChild(callout)
+	doc.AddChild(heading)
+
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	var decoded Document
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	again, err := json.Marshal(&decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+
+	assert.Equal(t, CRLF, decoded.LineEnding)
+	assert.True(t, decoded.BOM)
+
+	value, ok := decoded.FrontMatter.Get("week")
+	assert.True(t, ok)
+	assert.Equal(t, 42, value)
+
+	task := FindTasks(&decoded)[0]
+	assert.Equal(t, StatusInProgress, task.Status)
+	assert.Equal(t, 20, task.Metadata.Due.Day())
+	assert.Same(t, task, task.Inlines[1].Parent())
+	assert.Equal(t, "abc", task.Inlines[2].(*WikiLink).Block)
+
+	decodedList := task.Parent().(*List)
+	assert.Equal(t, 3, decodedList.Start)
+	assert.Equal(t, ")", decodedList.Marker)
+	assert.Same(t, &decoded, decodedList.Parent().Parent())
+
+	decodedHeading := FindHeadingByID(&decoded, "habits")
+	require.NotNil(t, decodedHeading)
+	assert.True(t, decodedHeading.Setext)
+	assert.Equal(t, ".wide", decodedHeading.Attributes)
+
+	decodedCallout := FindCallouts(&decoded)[0]
+	assert.Equal(t, "warning", decodedCallout.Kind)
+	assert.Equal(t, FoldCollapsed, decodedCallout.Fold)
+	assert.Len(t, decodedCallout.Children(), 1)
+}
+
+func TestMarshalNode(t *testing.T) {
+	task := NewTask(true, "Gym")
+	data, err := MarshalNode(task)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"task","status":"x","content":"Gym","modified":true}`, string(data))
+
+	// Nodes marshal the same way through encoding/json
+	viaJSON, err := json.Marshal(task)
+	require.NoError(t, err)
+	assert.Equal(t, data, viaJSON)
+
+	node, err := UnmarshalNode(data)
+	require.NoError(t, err)
+	assert.Equal(t, "Gym", node.(*Task).Content)
+	assert.True(t, node.(*Task).Checked())
+}
+
+func TestTaskMetadataJSON(t *testing.T) {
+	task := NewTask(false, "Gym 📅 2026-10-20 ⏫ [area:: health]")
+	data, err := MarshalNode(task)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"task","status":" ","content":"Gym 📅 2026-10-20 ⏫ [area:: health]","metadata":{"due":"2026-10-20","priority":4,"fields":{"area":"health"}},"modified":true}`, string(data))
+
+	// Editing the metadata rewrites the task's text to match
+	node, err := UnmarshalNode([]byte(`{"type":"task","content":"Gym 📅 2026-10-20","metadata":{"due":"2026-10-21","recurrence":"every week"}}`))
+	require.NoError(t, err)
+	decoded := node.(*Task)
+	assert.Equal(t, "Gym 📅 2026-10-21 🔁 every week", decoded.Content)
+	assert.Equal(t, "every week", decoded.Metadata.Recurrence)
+
+	_, err = UnmarshalNode([]byte(`{"type":"task","content":"Gym","metadata":{"due":"next week"}}`))
+	assert.EqualError(t, err, `invalid due date "next week"`)
+}
+
+func TestUnmarshalNodeErrors(t *testing.T) {
+	_, err := UnmarshalNode([]byte(`{"type":"video"}`))
+	assert.EqualError(t, err, `unknown node type "video"`)
+
+	_, err = UnmarshalNode([]byte(`{"type":"list","inlines":[{"type":"text"}]}`))
+	assert.EqualError(t, err, "list node cannot have inlines")
+
+	var doc Document
+	assert.EqualError(t, json.Unmarshal([]byte(`{"type":"task"}`), &doc), `expected a document node, got "task"`)
+}
@@ -0,0 +1,320 @@
+package markdown
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Priority is the priority of a task, written as one of the Obsidian Tasks
+// priority emoji
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLowest
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityHighest
+)
+
+// TaskMetadata holds the metadata written inline in a task's text, in the
+// Obsidian Tasks emoji format ("📅 2026-10-20", "⏫", "🔁 every week") and
+// the Dataview inline field format ("[key:: value]"). Zero values mean the
+// metadata is absent.
+type TaskMetadata struct {
+	Due        time.Time
+	Scheduled  time.Time
+	Start      time.Time
+	Created    time.Time
+	Completed  time.Time
+	Cancelled  time.Time
+	Priority   Priority
+	Recurrence string
+	Fields     map[string]string
+}
+
+// DateFormat is the layout of dates in task metadata
+const DateFormat = "2006-01-02"
+
+// metadataKind identifies a piece of emoji metadata
+type metadataKind int
+
+const (
+	kindPriority metadataKind = iota
+	kindRecurrence
+	kindCreated
+	kindStart
+	kindScheduled
+	kindDue
+	kindCompleted
+	kindCancelled
+)
+
+// signifiers maps each metadata emoji to its kind, in the order metadata is
+// appended to a task
+var signifiers = []struct {
+	emoji    string
+	kind     metadataKind
+	priority Priority
+}{
+	{"🔺", kindPriority, PriorityHighest},
+	{"⏫", kindPriority, PriorityHigh},
+	{"🔼", kindPriority, PriorityMedium},
+	{"🔽", kindPriority, PriorityLow},
+	{"⏬", kindPriority, PriorityLowest},
+	{"🔁", kindRecurrence, PriorityNone},
+	{"➕", kindCreated, PriorityNone},
+	{"🛫", kindStart, PriorityNone},
+	{"⏳", kindScheduled, PriorityNone},
+	{"📅", kindDue, PriorityNone},
+	{"✅", kindCompleted, PriorityNone},
+	{"❌", kindCancelled, PriorityNone},
+}
+
+// fieldRegex matches a Dataview inline field in square or round brackets
+var fieldRegex = regexp.MustCompile(`[\[(]([\w][\w -]*?)::\s*([^\[\]()]*?)\s*[\])]`)
+
+// metadataToken is a piece of metadata found in a task's text
+type metadataToken struct {
+	start, end int
+	kind       metadataKind
+	priority   Priority
+	value      string
+
+	// key is set for inline fields
+	key string
+}
+
+// ParseTaskMetadata extracts the inline metadata from a task's text
+func ParseTaskMetadata(text string) TaskMetadata {
+	var meta TaskMetadata
+	for _, token := range scanMetadata(text) {
+		if token.key != "" {
+			if meta.Fields == nil {
+				meta.Fields = map[string]string{}
+			}
+			meta.Fields[token.key] = token.value
+			continue
+		}
+
+		switch token.kind {
+		case kindPriority:
+			meta.Priority = token.priority
+		case kindRecurrence:
+			meta.Recurrence = token.value
+		default:
+			if date, err := time.Parse(DateFormat, token.value); err == nil {
+				*meta.date(token.kind) = date
+			}
+		}
+	}
+	return meta
+}
+
+// Description returns a task's text with its metadata removed
+func Description(text string) string {
+	var b strings.Builder
+	pos := 0
+	for _, token := range scanMetadata(text) {
+		b.WriteString(text[pos:token.start])
+		pos = token.end
+	}
+	b.WriteString(text[pos:])
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// FormatTaskMetadata returns text with its metadata updated to match meta.
+// Existing metadata is edited in place, metadata that is no longer set is
+// removed and new metadata is appended, so the rest of the text is untouched.
+func FormatTaskMetadata(text string, meta TaskMetadata) string {
+	tokens := scanMetadata(text)
+
+	var b strings.Builder
+	pos := 0
+	written := map[metadataKind]bool{}
+	writtenFields := map[string]bool{}
+	for _, token := range tokens {
+		var replacement string
+		if token.key != "" {
+			if value, ok := meta.Fields[token.key]; ok && !writtenFields[token.key] {
+				writtenFields[token.key] = true
+				open, close := text[token.start], text[token.end-1]
+				replacement = string(open) + token.key + ":: " + value + string(close)
+			}
+		} else if !written[token.kind] {
+			written[token.kind] = true
+			replacement = meta.format(token.kind)
+		}
+
+		start := token.start
+		if replacement == "" {
+			// Take the space before the removed metadata with it
+			start = len(strings.TrimRight(text[:start], " "))
+		}
+		b.WriteString(text[pos:start])
+		b.WriteString(replacement)
+		pos = token.end
+	}
+	b.WriteString(text[pos:])
+
+	for _, s := range signifiers {
+		if written[s.kind] {
+			continue
+		}
+		written[s.kind] = true
+		if formatted := meta.format(s.kind); formatted != "" {
+			b.WriteString(" " + formatted)
+		}
+	}
+
+	var keys []string
+	for key := range meta.Fields {
+		if !writtenFields[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(" [" + key + ":: " + meta.Fields[key] + "]")
+	}
+
+	return b.String()
+}
+
+// date returns the date field for a kind of metadata
+func (m *TaskMetadata) date(kind metadataKind) *time.Time {
+	switch kind {
+	case kindCreated:
+		return &m.Created
+	case kindStart:
+		return &m.Start
+	case kindScheduled:
+		return &m.Scheduled
+	case kindDue:
+		return &m.Due
+	case kindCompleted:
+		return &m.Completed
+	case kindCancelled:
+		return &m.Cancelled
+	}
+	return nil
+}
+
+// format renders a kind of metadata, or returns an empty string if it is
+// not set
+func (m TaskMetadata) format(kind metadataKind) string {
+	switch kind {
+	case kindPriority:
+		for _, s := range signifiers {
+			if s.kind == kindPriority && s.priority == m.Priority {
+				return s.emoji
+			}
+		}
+		return ""
+	case kindRecurrence:
+		if m.Recurrence == "" {
+			return ""
+		}
+		return emojiFor(kind) + " " + m.Recurrence
+	default:
+		date := m.date(kind)
+		if date.IsZero() {
+			return ""
+		}
+		return emojiFor(kind) + " " + date.Format(DateFormat)
+	}
+}
+
+// emojiFor returns the emoji for a kind of metadata other than priority
+func emojiFor(kind metadataKind) string {
+	for _, s := range signifiers {
+		if s.kind == kind {
+			return s.emoji
+		}
+	}
+	return ""
+}
+
+// scanMetadata finds the metadata in a task's text. The value of emoji
+// metadata runs until the next piece of metadata; dates take only the date
+// itself.
+func scanMetadata(text string) []metadataToken {
+	var tokens []metadataToken
+
+	for _, m := range fieldRegex.FindAllStringSubmatchIndex(text, -1) {
+		tokens = append(tokens, metadataToken{
+			start: m[0],
+			end:   m[1],
+			key:   strings.TrimSpace(text[m[2]:m[3]]),
+			value: text[m[4]:m[5]],
+		})
+	}
+
+	for i := 0; i < len(text); i++ {
+		for _, s := range signifiers {
+			if !strings.HasPrefix(text[i:], s.emoji) || insideToken(tokens, i) {
+				continue
+			}
+			end := i + len(s.emoji)
+			// Emoji are sometimes followed by a variation selector
+			end += len(variationSelector(text[end:]))
+			tokens = append(tokens, metadataToken{start: i, end: end, kind: s.kind, priority: s.priority})
+			i = end - 1
+			break
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].start < tokens[j].start })
+
+	// Extend emoji metadata over their values. A date emoji without a valid
+	// date is not metadata and stays part of the text.
+	var result []metadataToken
+	for i, token := range tokens {
+		if token.key != "" || token.kind == kindPriority {
+			result = append(result, token)
+			continue
+		}
+		limit := len(text)
+		if i+1 < len(tokens) {
+			limit = tokens[i+1].start
+		}
+		rest := text[token.end:limit]
+		value := strings.TrimSpace(rest)
+		if token.kind != kindRecurrence {
+			if len(value) < len(DateFormat) {
+				continue
+			}
+			value = value[:len(DateFormat)]
+			if _, err := time.Parse(DateFormat, value); err != nil {
+				continue
+			}
+		}
+		token.value = value
+		token.end += strings.Index(rest, value) + len(value)
+		result = append(result, token)
+	}
+
+	return result
+}
+
+// insideToken reports whether pos falls within one of the tokens
+func insideToken(tokens []metadataToken, pos int) bool {
+	for _, token := range tokens {
+		if pos >= token.start && pos < token.end {
+			return true
+		}
+	}
+	return false
+}
+
+// variationSelector returns the emoji variation selector at the start of
+// text, if there is one
+func variationSelector(text string) string {
+	if strings.HasPrefix(text, "\uFE0F") {
+		return "\uFE0F"
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package markdown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(s string) time.Time {
+	d, _ := time.Parse(DateFormat, s)
+	return d
+}
+
+func TestParseTaskMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		expected    TaskMetadata
+		description string
+	}{
+		{
+			name:        "No metadata",
+			text:        "Pay rent",
+			expected:    TaskMetadata{},
+			description: "Pay rent",
+		},
+		{
+			name:        "Due date and priority",
+			text:        "Pay rent 📅 2026-10-20 ⏫",
+			expected:    TaskMetadata{Due: date("2026-10-20"), Priority: PriorityHigh},
+			description: "Pay rent",
+		},
+		{
+			name: "Recurrence runs until the next metadata",
+			text: "Water plants 🔁 every week on Sunday ⏳ 2026-10-18 ➕ 2026-10-01",
+			expected: TaskMetadata{
+				Recurrence: "every week on Sunday",
+				Scheduled:  date("2026-10-18"),
+				Created:    date("2026-10-01"),
+			},
+			description: "Water plants",
+		},
+		{
+			name: "All dates",
+			text: "Ship 🛫 2026-10-01 ✅ 2026-10-05 ❌ 2026-10-06",
+			expected: TaskMetadata{
+				Start:     date("2026-10-01"),
+				Completed: date("2026-10-05"),
+				Cancelled: date("2026-10-06"),
+			},
+			description: "Ship",
+		},
+		{
+			name:        "Priority with variation selector",
+			text:        "Call mum 🔼️",
+			expected:    TaskMetadata{Priority: PriorityMedium},
+			description: "Call mum",
+		},
+		{
+			name:        "Inline fields",
+			text:        "Read [author:: Ursula K. Le Guin] (pages:: 300) book",
+			expected:    TaskMetadata{Fields: map[string]string{"author": "Ursula K. Le Guin", "pages": "300"}},
+			description: "Read book",
+		},
+		{
+			name:        "Invalid date is left in the text",
+			text:        "Dentist 📅 next tuesday",
+			expected:    TaskMetadata{},
+			description: "Dentist 📅 next tuesday",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseTaskMetadata(tt.text))
+			assert.Equal(t, tt.description, Description(tt.text))
+		})
+	}
+}
+
+func TestFormatTaskMetadata(t *testing.T) {
+	text := "Pay *rent* 📅 2026-10-20 ⏫ [owner:: me]"
+	meta := ParseTaskMetadata(text)
+
+	// Formatting unchanged metadata leaves the text as written
+	assert.Equal(t, text, FormatTaskMetadata(text, meta))
+
+	meta.Due = date("2026-11-20")
+	meta.Priority = PriorityNone
+	meta.Recurrence = "every month"
+	meta.Fields["owner"] = "you"
+	meta.Fields["account"] = "joint"
+	assert.Equal(t, "Pay *rent* 📅 2026-11-20 [owner:: you] 🔁 every month [account:: joint]", FormatTaskMetadata(text, meta))
+
+	assert.Equal(t, "Pay *rent*", FormatTaskMetadata(text, TaskMetadata{}))
+
+	task := NewTask(false, text)
+	assert.Equal(t, PriorityHigh, task.Metadata.Priority)
+	assert.Equal(t, "Pay *rent*", task.Description())
+
+	meta = task.Metadata
+	meta.Completed = date("2026-10-17")
+	task.SetMetadata(meta)
+	assert.Equal(t, text+" ✅ 2026-10-17", task.Content)
+	assert.Equal(t, date("2026-10-17"), task.Metadata.Completed)
+}
@@ -0,0 +1,312 @@
+package reader
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// kindWikiLink is the goldmark node kind for [[wikilinks]]
+var kindWikiLink = ast.NewNodeKind("WikiLink")
+
+// wikiLinkNode is the goldmark AST node produced by wikiLinkParser
+type wikiLinkNode struct {
+	ast.BaseInline
+	target string
+	alias  string
+	embed  bool
+}
+
+func (n *wikiLinkNode) Kind() ast.NodeKind { return kindWikiLink }
+
+func (n *wikiLinkNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.target, "Alias": n.alias}, nil)
+}
+
+// wikiLinkParser parses [[Target|Alias]] links and ![[Target]] embeds. It
+// runs before goldmark's link parser, which would otherwise read them as
+// bracketed text.
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'[', '!'}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+
+	node := &wikiLinkNode{}
+	prefix := 2
+	if bytes.HasPrefix(line, []byte("![[")) {
+		node.embed = true
+		prefix = 3
+	} else if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+
+	end := bytes.Index(line[prefix:], []byte("]]"))
+	if end <= 0 {
+		return nil
+	}
+	inner := string(line[prefix : prefix+end])
+	if strings.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+
+	node.target, node.alias, _ = strings.Cut(inner, "|")
+	block.Advance(prefix + end + 2)
+	return node
+}
+
+// kindTag is the goldmark node kind for #tags
+var kindTag = ast.NewNodeKind("Tag")
+
+// tagNode is the goldmark AST node produced by tagParser
+type tagNode struct {
+	ast.BaseInline
+	name string
+}
+
+func (n *tagNode) Kind() ast.NodeKind { return kindTag }
+
+func (n *tagNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.name}, nil)
+}
+
+// tagParser parses #tags. A tag starts after whitespace or at the start of
+// a line, and must contain at least one character that is not a digit so
+// that issue numbers like #42 stay plain text.
+type tagParser struct{}
+
+func (p *tagParser) Trigger() []byte {
+	return []byte{'#'}
+}
+
+func (p *tagParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if prev := block.PrecendingCharacter(); prev != '\n' && !unicode.IsSpace(prev) {
+		return nil
+	}
+
+	line, _ := block.PeekLine()
+	name := []rune{}
+	digits := true
+	for _, r := range string(line[1:]) {
+		if !isTagRune(r) {
+			break
+		}
+		if !unicode.IsDigit(r) {
+			digits = false
+		}
+		name = append(name, r)
+	}
+	if len(name) == 0 || digits {
+		return nil
+	}
+
+	block.Advance(1 + len(string(name)))
+	return &tagNode{name: string(name)}
+}
+
+// isTagRune reports whether r may appear in a tag name
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '/'
+}
+
+// convertInlines converts the inline children of a goldmark block into
+// markdown inline nodes
+func convertInlines(parent ast.Node, source []byte) []markdown.Node {
+	var nodes []markdown.Node
+
+	for child := parent.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *extast.TaskCheckBox:
+			// The checkbox is represented by the task itself
+
+		case *ast.Text:
+			content := string(n.Segment.Value(source))
+			if n.HardLineBreak() {
+				// Keep the trailing spaces or backslash that make the break
+				content += string(source[n.Segment.Stop:lineEnd(source, n.Segment.Stop)]) + "\n"
+			} else if n.SoftLineBreak() {
+				content += "\n"
+			}
+			nodes = appendText(nodes, content)
+
+		case *ast.String:
+			nodes = appendText(nodes, string(n.Value))
+
+		case *ast.Emphasis:
+			delimiter := strings.Repeat(string(delimiterChar(n, source)), n.Level)
+			children := convertInlines(n, source)
+			if n.Level == 2 {
+				nodes = append(nodes, markdown.NewStrong(delimiter, children...))
+			} else {
+				nodes = append(nodes, markdown.NewEmphasis(delimiter, children...))
+			}
+
+		case *ast.CodeSpan:
+			nodes = append(nodes, convertCodeSpan(n, source))
+
+		case *ast.Link:
+			link := markdown.NewLink(string(n.Destination), string(n.Title), convertInlines(n, source)...)
+			nodes = append(nodes, link)
+
+		case *ast.Image:
+			image := markdown.NewImage(string(n.Destination), string(n.Title), convertInlines(n, source)...)
+			nodes = append(nodes, image)
+
+		case *ast.AutoLink:
+			link := markdown.NewLink(string(n.URL(source)), "")
+			link.Autolink = true
+			nodes = append(nodes, link)
+
+		case *ast.RawHTML:
+			var raw strings.Builder
+			for i := 0; i < n.Segments.Len(); i++ {
+				segment := n.Segments.At(i)
+				raw.Write(segment.Value(source))
+			}
+			nodes = appendText(nodes, raw.String())
+
+		case *wikiLinkNode:
+			link := markdown.NewWikiLink(n.target, n.alias)
+			link.Embed = n.embed
+			nodes = append(nodes, link)
+
+		case *tagNode:
+			nodes = append(nodes, markdown.NewTag(n.name))
+
+		default:
+			// Unknown inlines keep their text
+			nodes = append(nodes, convertInlines(n, source)...)
+		}
+	}
+
+	return nodes
+}
+
+// appendText appends text to nodes, merging it into a preceding text node
+func appendText(nodes []markdown.Node, content string) []markdown.Node {
+	if len(nodes) > 0 {
+		if last, ok := nodes[len(nodes)-1].(*markdown.Text); ok {
+			last.Content += content
+			return nodes
+		}
+	}
+	return append(nodes, markdown.NewText(content))
+}
+
+// convertCodeSpan recovers a code span's raw content and delimiter from the
+// source surrounding its text
+func convertCodeSpan(n *ast.CodeSpan, source []byte) markdown.Node {
+	first, ok := n.FirstChild().(*ast.Text)
+	last, ok2 := n.LastChild().(*ast.Text)
+	if !ok || !ok2 {
+		return markdown.NewCodeSpan(string(n.Text(source)))
+	}
+
+	start, stop := first.Segment.Start, last.Segment.Stop
+	if start > 0 && source[start-1] == ' ' {
+		start--
+	}
+	if stop < len(source) && source[stop] == ' ' {
+		stop++
+	}
+
+	open := start
+	for open > 0 && source[open-1] == '`' {
+		open--
+	}
+
+	code := markdown.NewCodeSpan(string(source[start:stop]))
+	if open < start {
+		code.Delimiter = string(source[open:start])
+	}
+	return code
+}
+
+// delimiterChar returns the character used to delimit emphasis, found
+// immediately before the emphasised content
+func delimiterChar(n *ast.Emphasis, source []byte) byte {
+	if start, ok := inlineStart(n.FirstChild()); ok && start > 0 {
+		if c := source[start-1]; c == '_' {
+			return '_'
+		}
+	}
+	return '*'
+}
+
+// inlineStart returns the source offset an inline node's content starts at
+func inlineStart(node ast.Node) (int, bool) {
+	switch n := node.(type) {
+	case *ast.Text:
+		return n.Segment.Start, true
+	case *ast.Emphasis:
+		if start, ok := inlineStart(n.FirstChild()); ok {
+			return start - n.Level, true
+		}
+	case *ast.Link:
+		if start, ok := inlineStart(n.FirstChild()); ok {
+			return start - 1, true
+		}
+	case *ast.Image:
+		if start, ok := inlineStart(n.FirstChild()); ok {
+			return start - 2, true
+		}
+	}
+	return 0, false
+}
+
+// trimInlinePrefix removes the first n bytes of plain text from the start of
+// nodes, along with any whitespace that follows them
+func trimInlinePrefix(nodes []markdown.Node, n int) []markdown.Node {
+	for len(nodes) > 0 {
+		text, ok := nodes[0].(*markdown.Text)
+		if !ok {
+			return nodes
+		}
+		if n < len(text.Content) {
+			text.Content = strings.TrimLeft(text.Content[n:], " \t")
+			if text.Content != "" {
+				return nodes
+			}
+			n = 0
+		} else {
+			n -= len(text.Content)
+		}
+		nodes = nodes[1:]
+	}
+	return nodes
+}
+
+// trimInlineSuffix removes the last n bytes of text from inline nodes, along
+// with any spaces left at the end
+func trimInlineSuffix(nodes []markdown.Node, n int) []markdown.Node {
+	if n == 0 {
+		return nodes
+	}
+	for len(nodes) > 0 {
+		last := len(nodes) - 1
+		text, ok := nodes[last].(*markdown.Text)
+		if !ok {
+			return nodes
+		}
+		if n < len(text.Content) {
+			text.Content = strings.TrimRight(text.Content[:len(text.Content)-n], " \t")
+			if text.Content != "" {
+				return nodes
+			}
+			n = 0
+		} else {
+			n -= len(text.Content)
+		}
+		nodes = nodes[:last]
+	}
+	return nodes
+}
@@ -1,6 +1,7 @@
 package reader
 
 import (
+	"bytes"
 	"regexp"
 	"strings"
 
@@ -11,44 +12,213 @@ import (
 	extast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
-var taskRegex = regexp.MustCompile(`^\s*-\s*\[([ xX])\]\s*(.*)$`)
+var taskRegex = regexp.MustCompile(`^\s*-\s*\[(.)\]\s*(.*)$`)
+
+// checkboxRegex matches the checkbox prefix of a task list item's raw text
+var checkboxRegex = regexp.MustCompile(`^\[(.)\](?:\s+|$)`)
+
+// blockIDRegex matches a block reference ID at the end of a block's text
+var blockIDRegex = regexp.MustCompile(`[ \t]\^([A-Za-z0-9-]+)$`)
+
+// headingIDRegex matches the ID in a heading's attribute list
+var headingIDRegex = regexp.MustCompile(`(?:^|[ \t])#[^ \t]+`)
+
+// calloutRegex matches the first line of a callout: its kind, fold marker
+// and title
+var calloutRegex = regexp.MustCompile(`^\[!([^\]\s]+)\]([+-]?)(?:[ \t]+(.*))?$`)
+
+// definitionRegex matches the first line of a link reference definition,
+// or of a footnote definition when the label starts with "^"
+var definitionRegex = regexp.MustCompile(`^ {0,3}\[(\^?)[^\]]+\]:`)
+
+// bom is the UTF-8 byte order mark
+const bom = "\uFEFF"
+
+// Option configures how markdown is parsed
+type Option func(*builder)
+
+// WithTaskStatuses sets the checkbox characters that make a list item a
+// task. The default is markdown.DefaultTaskStatuses.
+func WithTaskStatuses(statuses markdown.TaskStatusSet) Option {
+	return func(b *builder) {
+		b.statuses = statuses
+	}
+}
 
 // ParseMarkdown parses markdown content and builds a tree structure
-func ParseMarkdown(content string) (*markdown.Document, error) {
+func ParseMarkdown(content string, opts ...Option) (*markdown.Document, error) {
 	md := goldmark.New(
-		goldmark.WithExtensions(extension.TaskList),
+		goldmark.WithExtensions(extension.TaskList, extension.Table),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
+			parser.WithAttribute(),
+			parser.WithInlineParsers(
+				util.Prioritized(&wikiLinkParser{}, 100),
+				util.Prioritized(&tagParser{}, 100),
+			),
 		),
 	)
-	source := []byte(content)
-
-	astDoc := md.Parser().Parse(text.NewReader(source))
-
-	// Create the document root
+	// The tree is built from LF-only source without a byte order mark; the
+	// document records what the file used so it is written back the same
+	// way. The blank space after the last block is kept as written and left
+	// out of the document's span.
 	doc := markdown.NewDocument()
+	content, doc.BOM = strings.CutPrefix(content, bom)
+	source, lineEnding, mixed := normalizeLineEndings(content)
+	doc.LineEnding = lineEnding
+	doc.SetMixedLineEndings(mixed)
+	doc.SetSource(source)
+	body, trailing := splitTrailing(content)
+	doc.Trailing = trailing
+	doc.SetSpan(markdown.Span{Start: 0, End: len(body) - strings.Count(body, "\r\n")})
+
+	// Front matter is blanked out rather than removed before handing the
+	// source to goldmark, so that offsets still line up with the original.
+	// A note can also open with a thematic break and have another further
+	// down, so the lines between are only front matter if they are empty or
+	// a YAML mapping.
+	parseSource := source
+	if frontMatter, span, ok := splitFrontMatter(source); ok {
+		if fm, err := markdown.ParseFrontMatter(frontMatter); err == nil {
+			fm.SetSpan(span)
+			doc.FrontMatter = fm
+			parseSource = blankRange(source, span)
+		}
+	}
+
+	astDoc := md.Parser().Parse(text.NewReader(parseSource))
 
 	// Build the tree by walking the AST
-	err := buildTree(doc, astDoc, source)
+	spans := computeSpans(astDoc, parseSource)
+	b := &builder{
+		md:          md,
+		source:      source,
+		spans:       spans,
+		definitions: definitionSpans(astDoc, parseSource, spans),
+		statuses:    markdown.DefaultTaskStatuses(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	err := b.buildTree(doc, astDoc)
+
+	// Headings own the content that follows them, so their spans are
+	// extended to cover their section once the tree is complete
+	finalizeTree(doc)
+	if doc.FrontMatter != nil {
+		doc.FrontMatter.ResetModified()
+	}
+
+	lines := newLineIndex(source)
+	setRanges(doc, lines)
+	if doc.FrontMatter != nil {
+		setRanges(doc.FrontMatter, lines)
+	}
 
 	return doc, err
 }
 
+// splitTrailing splits content after the line holding its last non-blank
+// character, so that spaces on that line stay with it
+func splitTrailing(content string) (string, string) {
+	end := len(strings.TrimRight(content, " \t\r\n"))
+	if i := strings.IndexAny(content[end:], "\r\n"); i >= 0 {
+		end += i
+	} else {
+		end = len(content)
+	}
+	return content[:end], content[end:]
+}
+
+// normalizeLineEndings replaces CRLF line endings with LF. It returns the
+// line ending of the first line and the offsets in the result of the line
+// endings written the other way.
+func normalizeLineEndings(content string) ([]byte, string, []int) {
+	source := make([]byte, 0, len(content))
+	lineEnding := ""
+	var mixed []int
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+			continue
+		}
+		if content[i] == '\n' {
+			ending := markdown.LF
+			if i > 0 && content[i-1] == '\r' {
+				ending = markdown.CRLF
+			}
+			if lineEnding == "" {
+				lineEnding = ending
+			}
+			if ending != lineEnding {
+				mixed = append(mixed, len(source))
+			}
+		}
+		source = append(source, content[i])
+	}
+	if lineEnding == markdown.LF {
+		lineEnding = ""
+	}
+	return source, lineEnding, mixed
+}
+
+// builder converts a goldmark AST into a markdown tree
+type builder struct {
+	md     goldmark.Markdown
+	source []byte
+
+	// spans holds the source range of every block in the AST
+	spans map[ast.Node]markdown.Span
+
+	// definitions holds the spans of top-level link reference and footnote
+	// definitions, which have no AST node, in document order
+	definitions []markdown.Span
+
+	// statuses holds the checkbox characters recognised as tasks
+	statuses markdown.TaskStatusSet
+}
+
 // buildTree recursively builds our tree from the goldmark AST
-func buildTree(parent markdown.Node, astNode ast.Node, source []byte) error {
+func (b *builder) buildTree(parent markdown.Node, astNode ast.Node) error {
 	var headingStack []*markdown.Heading
 
+	// add puts a node under the innermost open heading, or under parent
+	add := func(node markdown.Node) {
+		if len(headingStack) > 0 {
+			headingStack[len(headingStack)-1].AddChild(node)
+		} else {
+			parent.AddChild(node)
+		}
+	}
+
+	// Definitions are only found at the top level, between its blocks
+	_, topLevel := astNode.(*ast.Document)
+	addDefinitions := func(before int) {
+		for topLevel && len(b.definitions) > 0 && b.definitions[0].Start < before {
+			add(b.definition(b.definitions[0]))
+			b.definitions = b.definitions[1:]
+		}
+	}
+
 	// Process each child of the current AST node
 	for child := astNode.FirstChild(); child != nil; child = child.NextSibling() {
-		node, err := convertASTNode(child, source)
+		if span, ok := b.spans[child]; ok {
+			addDefinitions(span.Start)
+		}
+
+		node, err := b.convertASTNode(child)
 		if err != nil {
 			return err
 		}
 
 		if node != nil {
-			//fmt.Printf("Created node: %T\n", node)
+			if span, ok := b.spans[child]; ok {
+				node.SetSpan(span)
+			}
+			b.setOwnSpan(node, child)
+
 			switch n := node.(type) {
 			case *markdown.Heading:
 				// Pop headings from stack that are at same or higher level
@@ -56,35 +226,68 @@ func buildTree(parent markdown.Node, astNode ast.Node, source []byte) error {
 					headingStack = headingStack[:len(headingStack)-1]
 				}
 
-				// Add to appropriate parent
-				if len(headingStack) > 0 {
-					headingStack[len(headingStack)-1].AddChild(n)
-				} else {
-					parent.AddChild(n)
-				}
+				add(n)
 
 				// Push to stack
 				headingStack = append(headingStack, n)
 
 			default:
 				// Add to current heading if exists, otherwise to parent
-				if len(headingStack) > 0 {
-					headingStack[len(headingStack)-1].AddChild(node)
-				} else {
-					parent.AddChild(node)
-				}
+				add(node)
 			}
 
-			// Recursively process children only for non-text nodes
-			if _, isText := node.(*markdown.Text); !isText {
-				if err := buildTree(node, child, source); err != nil {
-					return err
-				}
+			// Recursively process the block children that were not
+			// already folded into the node's content
+			if err := b.buildBlockChildren(node, child); err != nil {
+				return err
 			}
 		} else {
 			// If we didn't create a node for this AST node, process its children
 			// directly under the current parent
-			if err := buildTree(parent, child, source); err != nil {
+			if err := b.buildTree(parent, child); err != nil {
+				return err
+			}
+		}
+	}
+	addDefinitions(len(b.source) + 1)
+
+	return nil
+}
+
+// definition creates a raw block for a link reference or footnote
+// definition
+func (b *builder) definition(span markdown.Span) markdown.Node {
+	content := string(b.source[span.Start:span.End])
+	kind := markdown.RawLinkReference
+	if m := definitionRegex.FindStringSubmatch(content); m != nil && m[1] == "^" {
+		kind = markdown.RawFootnote
+	}
+	raw := markdown.NewRawBlock(kind, content)
+	raw.SetSpan(span)
+	return raw
+}
+
+// buildBlockChildren builds the children of a converted node. Leaf blocks
+// carry their text in Content, and a list item's first block is its content,
+// so only the remaining blocks become child nodes.
+func (b *builder) buildBlockChildren(node markdown.Node, astNode ast.Node) error {
+	switch astNode.(type) {
+	case *ast.List, *extast.Table, *extast.TableHeader, *extast.TableRow:
+		return b.buildTree(node, astNode)
+
+	case *ast.Blockquote:
+		if callout, ok := node.(*markdown.Callout); ok {
+			return b.buildCallout(callout, astNode)
+		}
+		return b.buildTree(node, astNode)
+
+	case *ast.ListItem:
+		first := astNode.FirstChild()
+		if !isTextBlock(first) {
+			return b.buildTree(node, astNode)
+		}
+		for child := first.NextSibling(); child != nil; child = child.NextSibling() {
+			if err := b.buildSibling(node, child); err != nil {
 				return err
 			}
 		}
@@ -93,123 +296,457 @@ func buildTree(parent markdown.Node, astNode ast.Node, source []byte) error {
 	return nil
 }
 
+// buildCallout builds the children of a callout. The first line of its
+// first paragraph is the callout's own line, so only the lines after it
+// become a paragraph.
+func (b *builder) buildCallout(callout *markdown.Callout, astNode ast.Node) error {
+	first := astNode.FirstChild()
+	if lines := first.Lines(); lines.Len() > 1 {
+		var text strings.Builder
+		for i := 1; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			text.Write(segment.Value(b.source))
+		}
+		content := strings.TrimSpace(text.String())
+		para := markdown.NewParagraph(content)
+		para.SetInlines(b.parseInlines(content)...)
+		setBlockID(para)
+		if span, ok := b.spans[first]; ok {
+			para.SetSpan(markdown.Span{Start: lineStart(b.source, lines.At(1).Start), End: span.End})
+		}
+		callout.AddChild(para)
+	}
+	for child := first.NextSibling(); child != nil; child = child.NextSibling() {
+		if err := b.buildSibling(callout, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseInlines parses text that is only part of a block, such as a callout
+// title, into inline nodes
+func (b *builder) parseInlines(content string) []markdown.Node {
+	if content == "" {
+		return nil
+	}
+	source := []byte(content)
+	doc := b.md.Parser().Parse(text.NewReader(source))
+	if para, ok := doc.FirstChild().(*ast.Paragraph); ok && para.NextSibling() == nil {
+		return convertInlines(para, source)
+	}
+	return []markdown.Node{markdown.NewText(content)}
+}
+
+// buildSibling converts a single AST node and adds it to parent
+func (b *builder) buildSibling(parent markdown.Node, astNode ast.Node) error {
+	node, err := b.convertASTNode(astNode)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return b.buildTree(parent, astNode)
+	}
+	if span, ok := b.spans[astNode]; ok {
+		node.SetSpan(span)
+	}
+	b.setOwnSpan(node, astNode)
+	parent.AddChild(node)
+	return b.buildBlockChildren(node, astNode)
+}
+
+// setOwnSpan records the lines a container occupies before its children:
+// a heading's own line, or a list item's marker and text
+func (b *builder) setOwnSpan(node markdown.Node, astNode ast.Node) {
+	span, ok := b.spans[astNode]
+	if !ok {
+		return
+	}
+
+	switch astNode.(type) {
+	case *ast.Heading:
+		node.SetOwnSpan(span)
+
+	case *ast.Blockquote:
+		if _, ok := node.(*markdown.Callout); ok {
+			node.SetOwnSpan(markdown.Span{Start: span.Start, End: lineEnd(b.source, span.Start+1)})
+		}
+
+	case *ast.ListItem:
+		if first := astNode.FirstChild(); isTextBlock(first) {
+			if text, ok := b.spans[first]; ok {
+				node.SetOwnSpan(markdown.Span{Start: span.Start, End: text.End})
+			}
+		}
+	}
+}
+
 // convertASTNode converts a goldmark AST node to our markdown node
-func convertASTNode(astNode ast.Node, source []byte) (markdown.Node, error) {
-	// Debug: print the AST node type
-	//fmt.Printf("Converting AST node: %T\n", astNode)
+func (b *builder) convertASTNode(astNode ast.Node) (markdown.Node, error) {
+	source := b.source
+
+	// A paragraph made up only of link reference definitions is left with
+	// no lines once goldmark takes them out; the definitions are kept as
+	// raw blocks instead
+	switch astNode.(type) {
+	case *ast.Paragraph, *ast.TextBlock:
+		if astNode.Lines().Len() == 0 {
+			return nil, nil
+		}
+	}
 
 	switch node := astNode.(type) {
 	case *ast.Heading:
-		title := extractNodeText(node, source)
-		return markdown.NewHeading(node.Level, title), nil
+		title := rawBlockText(node, source)
+		heading := markdown.NewHeading(node.Level, title)
+		heading.SetInlines(convertInlines(node, source)...)
+		heading.Setext = isSetextHeading(node, source)
+		setHeadingIDs(heading, node, source)
+		return heading, nil
 
 	case *ast.Paragraph:
 		// Check if this paragraph contains a task
-		text := extractNodeText(node, source)
-		if task := parseTask(text); task != nil {
+		text := rawBlockText(node, source)
+		if m := definitionRegex.FindStringSubmatch(text); m != nil && m[1] == "^" {
+			// Footnote definitions are not parsed, so goldmark leaves
+			// them as paragraphs
+			return markdown.NewRawBlock(markdown.RawFootnote, codeBlockText(node, source)), nil
+		}
+		if task := parseTask(text, b.statuses); task != nil {
+			setBlockID(task)
 			return task, nil
 		}
-		return markdown.NewParagraph(text), nil
+		para := markdown.NewParagraph(text)
+		para.SetInlines(convertInlines(node, source)...)
+		setBlockID(para)
+		return para, nil
 
 	case *ast.List:
-		ordered := node.IsOrdered()
-		return markdown.NewList(ordered), nil
+		list := markdown.NewList(node.IsOrdered())
+		if list.Ordered {
+			list.Start = node.Start
+		}
+		list.Marker = string(node.Marker)
+		return list, nil
 
 	case *ast.ListItem:
-		// Check if this list item contains a task checkbox
-		hasTaskCheckBox := false
-		var isChecked bool
-
-		// Walk through all descendants to find TaskCheckBox
-		ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
-			if entering {
-				if taskBox, ok := n.(*extast.TaskCheckBox); ok {
-					hasTaskCheckBox = true
-					isChecked = taskBox.IsChecked
-					return ast.WalkStop, nil
+		// The item's own text is its first block; anything after it
+		// (nested lists and so on) becomes children
+		var content string
+		var inlines []markdown.Node
+		if first := node.FirstChild(); isTextBlock(first) {
+			content = rawBlockText(first, source)
+			inlines = convertInlines(first, source)
+		}
+
+		// goldmark only recognises space, x and X as checkboxes, so any
+		// other status is stripped from the inlines here
+		if m := checkboxRegex.FindStringSubmatch(content); m != nil {
+			status := markdown.TaskStatus([]rune(m[1])[0])
+			if _, ok := b.statuses[status]; ok {
+				if !hasTaskCheckBox(node) {
+					inlines = trimInlinePrefix(inlines, len(m[0]))
 				}
+				task := markdown.NewTaskWithStatus(status, content[len(m[0]):])
+				task.SetInlines(inlines...)
+				setBlockID(task)
+				return task, nil
+			}
+		}
+
+		item := markdown.NewListItem(content)
+		item.SetInlines(inlines...)
+		setBlockID(item)
+		return item, nil
+
+	case *ast.FencedCodeBlock:
+		code := markdown.NewCodeBlock(string(node.Language(source)), codeBlockText(node, source))
+		if node.Info != nil {
+			code.Info = strings.TrimSpace(string(node.Info.Segment.Value(source)))
+		}
+		if span, ok := b.spans[node]; ok {
+			code.Fence = fenceMarker(source[span.Start:lineEnd(source, span.Start+1)])
+		}
+		return code, nil
+
+	case *ast.CodeBlock:
+		code := markdown.NewCodeBlock("", codeBlockText(node, source))
+		code.Fenced = false
+		return code, nil
+
+	case *ast.Blockquote:
+		if first, ok := node.FirstChild().(*ast.Paragraph); ok && first.Lines().Len() > 0 {
+			segment := first.Lines().At(0)
+			line := strings.TrimSpace(string(segment.Value(source)))
+			if m := calloutRegex.FindStringSubmatch(line); m != nil {
+				callout := markdown.NewCallout(m[1], m[3])
+				callout.Fold = markdown.CalloutFold(m[2])
+				callout.SetInlines(b.parseInlines(callout.Title)...)
+				return callout, nil
 			}
-			return ast.WalkContinue, nil
-		})
+		}
+		return markdown.NewBlockquote(), nil
 
-		// Extract the content (excluding the checkbox)
-		content := extractListItemText(node, source)
+	case *ast.ThematicBreak:
+		marker := "---"
+		if span, ok := b.spans[node]; ok {
+			marker = strings.TrimLeft(string(source[span.Start:span.End]), " >\t")
+		}
+		return markdown.NewThematicBreak(marker), nil
 
-		if hasTaskCheckBox {
-			return markdown.NewTask(isChecked, content), nil
+	case *extast.Table:
+		var alignments []markdown.Alignment
+		for _, alignment := range node.Alignments {
+			alignments = append(alignments, convertAlignment(alignment))
 		}
+		return markdown.NewTable(alignments...), nil
 
-		return markdown.NewListItem(content), nil
+	case *extast.TableHeader:
+		return markdown.NewTableRow(true), nil
+
+	case *extast.TableRow:
+		return markdown.NewTableRow(false), nil
+
+	case *extast.TableCell:
+		cell := markdown.NewTableCell(rawBlockText(node, source))
+		cell.SetInlines(convertInlines(node, source)...)
+		return cell, nil
 
 	case *extast.TaskCheckBox:
 		// These are handled in the ListItem case
 		return nil, nil
 
-	case *ast.TextBlock:
-		// Handle text blocks
-		content := extractNodeText(node, source)
-		return markdown.NewText(content), nil
-
-	case *ast.Text:
-		// Skip text nodes that are direct children of headings
-		// as the heading text is already extracted in the heading creation
-		if parent := astNode.Parent(); parent != nil {
-			if _, ok := parent.(*ast.Heading); ok {
-				return nil, nil
+	case *ast.HTMLBlock:
+		content := codeBlockText(node, source)
+		if node.HasClosure() {
+			if content != "" {
+				content += "\n"
 			}
+			content += strings.TrimRight(string(node.ClosureLine.Value(source)), "\r\n")
 		}
-		content := string(node.Segment.Value(source))
+		return markdown.NewRawBlock(markdown.RawHTML, content), nil
+
+	case *ast.TextBlock:
+		// Handle text blocks
+		content := rawBlockText(node, source)
 		return markdown.NewText(content), nil
 
 	default:
-		// For other node types, we'll process their children but not create a node
+		// Blocks the tree does not model are kept as they were written.
+		// Anything else has its children processed without creating a node.
+		if astNode.Type() == ast.TypeBlock && astNode.Lines().Len() > 0 && !astNode.HasChildren() {
+			return markdown.NewRawBlock(astNode.Kind().String(), codeBlockText(astNode, source)), nil
+		}
 		return nil, nil
 	}
 }
 
-// extractNodeText extracts all text content from a node
-func extractNodeText(node ast.Node, source []byte) string {
+// convertAlignment converts a goldmark table column alignment
+func convertAlignment(alignment extast.Alignment) markdown.Alignment {
+	switch alignment {
+	case extast.AlignLeft:
+		return markdown.AlignLeft
+	case extast.AlignCenter:
+		return markdown.AlignCenter
+	case extast.AlignRight:
+		return markdown.AlignRight
+	}
+	return markdown.AlignNone
+}
+
+// isTextBlock reports whether node holds inline text directly
+func isTextBlock(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.TextBlock, *ast.Paragraph:
+		return true
+	}
+	return false
+}
+
+// hasTaskCheckBox reports whether the first block of a list item starts with
+// a task checkbox
+func hasTaskCheckBox(item *ast.ListItem) bool {
+	first := item.FirstChild()
+	if !isTextBlock(first) {
+		return false
+	}
+	_, ok := first.FirstChild().(*extast.TaskCheckBox)
+	return ok
+}
+
+// codeBlockText returns the content lines of a code block without the
+// final line ending
+func codeBlockText(node ast.Node, source []byte) string {
 	var text strings.Builder
 
-	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
-		if entering {
-			if textNode, ok := n.(*ast.Text); ok {
-				text.Write(textNode.Segment.Value(source))
-			}
-		}
-		return ast.WalkContinue, nil
-	})
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		text.Write(segment.Value(source))
+	}
 
-	return strings.TrimSpace(text.String())
+	return strings.TrimSuffix(strings.TrimSuffix(text.String(), "\n"), "\r")
+}
+
+// setHeadingIDs records a heading's "{...}" attribute list, which goldmark
+// removes from its text, and its ID. goldmark gives every heading an ID, so
+// it is only explicit when the attribute list contains one.
+func setHeadingIDs(heading *markdown.Heading, node *ast.Heading, source []byte) {
+	var id string
+	switch value, _ := node.AttributeString("id"); v := value.(type) {
+	case []byte:
+		id = string(v)
+	case string:
+		id = v
+	}
+
+	attributes, ok := headingAttributes(node, source)
+	if ok && headingIDRegex.MatchString(attributes) {
+		heading.ID = id
+		heading.Attributes = strings.TrimSpace(headingIDRegex.ReplaceAllString(attributes, ""))
+		return
+	}
+	heading.Attributes = attributes
+	heading.AutoID = id
+}
+
+// headingAttributes returns the text inside a heading's attribute list,
+// which follows the heading's last line of text
+func headingAttributes(node *ast.Heading, source []byte) (string, bool) {
+	lines := node.Lines()
+	if lines.Len() == 0 {
+		return "", false
+	}
+	stop := lines.At(lines.Len() - 1).Stop
+	rest := string(source[stop:max(stop, lineEnd(source, stop))])
+	open, end := strings.IndexByte(rest, '{'), strings.LastIndexByte(rest, '}')
+	if open < 0 || end < open {
+		return "", false
+	}
+	return strings.TrimSpace(rest[open+1 : end]), true
 }
 
-// extractListItemText extracts text content from a list item, excluding task checkbox
-func extractListItemText(node ast.Node, source []byte) string {
+// rawBlockText returns the raw markdown of a leaf block's lines, preserving
+// inline markup such as emphasis and links
+func rawBlockText(node ast.Node, source []byte) string {
 	var text strings.Builder
 
-	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
-		if entering {
-			// Skip TaskCheckBox nodes
-			if _, ok := n.(*extast.TaskCheckBox); ok {
-				return ast.WalkSkipChildren, nil
-			}
-			if textNode, ok := n.(*ast.Text); ok {
-				text.Write(textNode.Segment.Value(source))
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		text.Write(segment.Value(source))
+	}
+
+	return strings.TrimSpace(text.String())
+}
+
+// splitFrontMatter returns the YAML between a leading pair of "---"
+// delimiters and the span covering the delimiters and YAML
+func splitFrontMatter(source []byte) (string, markdown.Span, bool) {
+	pos := 0
+	for line := 0; pos < len(source); line++ {
+		end := len(source)
+		if i := bytes.IndexByte(source[pos:], '\n'); i >= 0 {
+			end = pos + i
+		}
+		text := strings.TrimSuffix(string(source[pos:end]), "\r")
+
+		switch {
+		case line == 0 && text != "---":
+			return "", markdown.Span{}, false
+		case line > 0 && (text == "---" || text == "..."):
+			body := bytes.IndexByte(source, '\n') + 1
+			return string(source[body:pos]), markdown.Span{Start: 0, End: pos + len(text)}, true
+		}
+
+		pos = end + 1
+	}
+
+	return "", markdown.Span{}, false
+}
+
+// blankRange returns a copy of source with every byte in span other than
+// line endings replaced by a space
+func blankRange(source []byte, span markdown.Span) []byte {
+	blanked := make([]byte, len(source))
+	copy(blanked, source)
+	for i := span.Start; i < span.End; i++ {
+		if blanked[i] != '\n' && blanked[i] != '\r' {
+			blanked[i] = ' '
+		}
+	}
+	return blanked
+}
+
+// finalizeTree extends heading spans over their sections and records every
+// node's parsed state so later modifications can be detected
+func finalizeTree(node markdown.Node) {
+	for _, child := range node.Children() {
+		finalizeTree(child)
+	}
+	if container, ok := node.(markdown.InlineContainer); ok {
+		for _, inline := range container.InlineNodes() {
+			finalizeTree(inline)
+		}
+	}
+
+	if h, ok := node.(*markdown.Heading); ok {
+		if span, ok := h.Span(); ok {
+			children := h.Children()
+			if len(children) > 0 {
+				if last, ok := children[len(children)-1].Span(); ok && last.End > span.End {
+					span.End = last.End
+					h.SetSpan(span)
+				}
 			}
 		}
-		return ast.WalkContinue, nil
-	})
+	}
 
-	return strings.TrimSpace(text.String())
+	node.ResetModified()
 }
 
-// parseTask checks if text is a task and returns a Task node if it is
-func parseTask(text string) *markdown.Task {
+// setBlockID moves a trailing "^id" block reference out of a task, list
+// item or paragraph's text and into its BlockID
+func setBlockID(node markdown.Node) {
+	switch n := node.(type) {
+	case *markdown.Task:
+		var trimmed int
+		n.Content, n.BlockID, trimmed = splitBlockID(n.Content)
+		n.Inlines = trimInlineSuffix(n.Inlines, trimmed)
+		n.Metadata = markdown.ParseTaskMetadata(n.Content)
+	case *markdown.ListItem:
+		var trimmed int
+		n.Content, n.BlockID, trimmed = splitBlockID(n.Content)
+		n.Inlines = trimInlineSuffix(n.Inlines, trimmed)
+	case *markdown.Paragraph:
+		var trimmed int
+		n.Content, n.BlockID, trimmed = splitBlockID(n.Content)
+		n.Inlines = trimInlineSuffix(n.Inlines, trimmed)
+	}
+}
+
+// splitBlockID splits a trailing block reference ID from text, returning
+// the text without it, the ID and the number of bytes removed
+func splitBlockID(text string) (string, string, int) {
+	loc := blockIDRegex.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return text, "", 0
+	}
+	rest := strings.TrimRight(text[:loc[0]], " \t")
+	return rest, text[loc[2]:loc[3]], len(text) - len(rest)
+}
+
+// parseTask checks if text is a task with one of the given statuses and
+// returns a Task node if it is
+func parseTask(text string, statuses markdown.TaskStatusSet) *markdown.Task {
 	matches := taskRegex.FindStringSubmatch(text)
 	if len(matches) == 3 {
-		checked := matches[1] != " "
+		status := markdown.TaskStatus([]rune(matches[1])[0])
+		if _, ok := statuses[status]; !ok {
+			return nil
+		}
 		content := matches[2]
-		return markdown.NewTask(checked, content)
+		return markdown.NewTaskWithStatus(status, content)
 	}
 	return nil
 }
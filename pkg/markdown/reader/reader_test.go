@@ -1,6 +1,7 @@
 package reader
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/notedownorg/planner/pkg/markdown"
@@ -68,11 +69,11 @@ This is a paragraph.`,
 			validate: func(t *testing.T, doc *markdown.Document) {
 				tasks := markdown.FindTasks(doc)
 				assert.Len(t, tasks, 3)
-				assert.True(t, tasks[0].Checked)
+				assert.True(t, tasks[0].Checked())
 				assert.Equal(t, "Completed task", tasks[0].Content)
-				assert.False(t, tasks[1].Checked)
+				assert.False(t, tasks[1].Checked())
 				assert.Equal(t, "Incomplete task", tasks[1].Content)
-				assert.True(t, tasks[2].Checked)
+				assert.True(t, tasks[2].Checked())
 			},
 		},
 		{
@@ -141,7 +142,21 @@ Todo list pending.`,
 
 				assert.Len(t, lists, 2)
 				assert.False(t, lists[0].Ordered)
+				assert.Equal(t, "-", lists[0].Marker)
 				assert.True(t, lists[1].Ordered)
+				assert.Equal(t, 1, lists[1].Start)
+				assert.Equal(t, ".", lists[1].Marker)
+			},
+		},
+		{
+			name:    "Ordered list start and delimiter",
+			content: "7) Seventh\n8) Eighth",
+			validate: func(t *testing.T, doc *markdown.Document) {
+				list, ok := doc.Children()[0].(*markdown.List)
+				assert.True(t, ok)
+				assert.True(t, list.Ordered)
+				assert.Equal(t, 7, list.Start)
+				assert.Equal(t, ")", list.Marker)
 			},
 		},
 	}
@@ -165,7 +180,7 @@ func TestParseTask(t *testing.T) {
 			name:  "Checked task with x",
 			input: "- [x] Completed task",
 			expected: &markdown.Task{
-				Checked: true,
+				Status:  markdown.StatusDone,
 				Content: "Completed task",
 			},
 		},
@@ -173,7 +188,7 @@ func TestParseTask(t *testing.T) {
 			name:  "Checked task with X",
 			input: "- [X] Completed task",
 			expected: &markdown.Task{
-				Checked: true,
+				Status:  'X',
 				Content: "Completed task",
 			},
 		},
@@ -181,7 +196,7 @@ func TestParseTask(t *testing.T) {
 			name:  "Unchecked task",
 			input: "- [ ] Incomplete task",
 			expected: &markdown.Task{
-				Checked: false,
+				Status:  markdown.StatusTodo,
 				Content: "Incomplete task",
 			},
 		},
@@ -189,7 +204,7 @@ func TestParseTask(t *testing.T) {
 			name:  "Task with leading spaces",
 			input: "  - [x] Indented task",
 			expected: &markdown.Task{
-				Checked: true,
+				Status:  markdown.StatusDone,
 				Content: "Indented task",
 			},
 		},
@@ -207,13 +222,13 @@ func TestParseTask(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			task := parseTask(tt.input)
+			task := parseTask(tt.input, markdown.DefaultTaskStatuses())
 
 			if tt.expected == nil {
 				assert.Nil(t, task)
 			} else {
 				assert.NotNil(t, task)
-				assert.Equal(t, tt.expected.Checked, task.Checked)
+				assert.Equal(t, tt.expected.Status, task.Status)
 				assert.Equal(t, tt.expected.Content, task.Content)
 			}
 		})
@@ -271,9 +286,552 @@ Discussed timeline and deliverables.
 	// Count completed tasks
 	completed := 0
 	for _, task := range tasks {
-		if task.Checked {
+		if task.Checked() {
 			completed++
 		}
 	}
 	assert.Equal(t, 2, completed)
 }
+
+func TestParseMarkdownSpans(t *testing.T) {
+	content := "# Week 42\n\nSome **bold** notes.\n\n## Habits\n- [ ] Read [[Atomic Habits]]\n- [x] Gym\n  - Warm up\n"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(doc.Source()))
+
+	spanText := func(node markdown.Node) string {
+		span, ok := node.Span()
+		assert.True(t, ok)
+		return content[span.Start:span.End]
+	}
+
+	week := markdown.FindHeadingByTitle(doc, "Week 42")
+	assert.Equal(t, strings.TrimSuffix(content, "\n"), spanText(week))
+
+	para := week.Children()[0].(*markdown.Paragraph)
+	assert.Equal(t, "Some **bold** notes.", para.Content)
+	assert.Equal(t, "Some **bold** notes.", spanText(para))
+
+	tasks := markdown.FindTasks(doc)
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, "Read [[Atomic Habits]]", tasks[0].Content)
+	assert.Equal(t, "- [ ] Read [[Atomic Habits]]", spanText(tasks[0]))
+	assert.Equal(t, "- [x] Gym\n  - Warm up", spanText(tasks[1]))
+
+	// Nested items are children rather than part of the task's content
+	assert.Equal(t, "Gym", tasks[1].Content)
+	assert.Len(t, tasks[1].Children(), 1)
+
+	// Freshly parsed nodes are pristine
+	for _, task := range tasks {
+		assert.False(t, task.Modified())
+	}
+}
+
+func TestParseMarkdownRanges(t *testing.T) {
+	content := "---\ntitle: Week 42\n---\n# Week 42\n\n## Habits\n- [ ] Read 📖\n- [x] Gym\n  - Warm up\n"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	pos := func(line, column int) markdown.Position {
+		return markdown.Position{Line: line, Column: column}
+	}
+	rangeOf := func(node markdown.Node) markdown.Range {
+		r, ok := node.Range()
+		assert.True(t, ok)
+		return r
+	}
+
+	assert.Equal(t, markdown.Range{Start: pos(1, 1), End: pos(3, 4)}, rangeOf(doc.FrontMatter))
+
+	week := markdown.FindHeadingByTitle(doc, "Week 42")
+	assert.Equal(t, markdown.Range{Start: pos(4, 1), End: pos(9, 12)}, rangeOf(week))
+
+	habits := markdown.FindHeadingByTitle(doc, "Habits")
+	assert.Equal(t, pos(6, 1), rangeOf(habits).Start)
+
+	tasks := markdown.FindAllTasks(doc)
+	assert.Len(t, tasks, 2)
+	// Columns count characters, so the emoji is one column wide
+	assert.Equal(t, markdown.Range{Start: pos(7, 1), End: pos(7, 13)}, rangeOf(tasks[0]))
+	assert.Equal(t, markdown.Range{Start: pos(8, 1), End: pos(9, 12)}, rangeOf(tasks[1]))
+
+	// Nested blocks start at their indentation
+	item := tasks[1].Children()[0].Children()[0]
+	assert.Equal(t, markdown.Range{Start: pos(9, 3), End: pos(9, 12)}, rangeOf(item))
+	assert.Equal(t, "line 9, column 3", rangeOf(item).Start.String())
+
+	// Nodes built in code have no range
+	_, ok := markdown.NewParagraph("New").Range()
+	assert.False(t, ok)
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		validate func(t *testing.T, doc *markdown.Document)
+	}{
+		{
+			name:    "Front matter before heading",
+			content: "---\ntags: [weekly]\nstatus: draft\n---\n\n# Week 42",
+			validate: func(t *testing.T, doc *markdown.Document) {
+				assert.NotNil(t, doc.FrontMatter)
+				assert.Equal(t, []string{"tags", "status"}, doc.FrontMatter.Keys())
+
+				// The delimiters must not be mistaken for a setext heading
+				headings := markdown.FindHeadings(doc)
+				assert.Len(t, headings, 1)
+				assert.Equal(t, "Week 42", headings[0].Title)
+			},
+		},
+		{
+			name:    "No front matter",
+			content: "# Week 42\n\n---\n\ntitle: not front matter",
+			validate: func(t *testing.T, doc *markdown.Document) {
+				assert.Nil(t, doc.FrontMatter)
+			},
+		},
+		{
+			name:    "Thematic breaks around a heading",
+			content: "---\n# Week 42\n\nSome notes\n\n---\n\nMore\n",
+			validate: func(t *testing.T, doc *markdown.Document) {
+				assert.Nil(t, doc.FrontMatter)
+				assert.Equal(t, "Week 42", markdown.FindHeadings(doc)[0].Title)
+				assert.IsType(t, &markdown.ThematicBreak{}, doc.Children()[0])
+			},
+		},
+		{
+			name:    "Thematic breaks around a paragraph",
+			content: "---\nIntro\n---\n\n- [ ] a\n",
+			validate: func(t *testing.T, doc *markdown.Document) {
+				assert.Nil(t, doc.FrontMatter)
+				assert.Equal(t, "a", markdown.FindTasks(doc)[0].Content)
+			},
+		},
+		{
+			name:    "Invalid YAML",
+			content: "---\n: [invalid\n---\n",
+			validate: func(t *testing.T, doc *markdown.Document) {
+				assert.Nil(t, doc.FrontMatter)
+			},
+		},
+		{
+			name:    "Unterminated front matter",
+			content: "---\ntitle: Week 42",
+			validate: func(t *testing.T, doc *markdown.Document) {
+				assert.Nil(t, doc.FrontMatter)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseMarkdown(tt.content)
+			assert.NoError(t, err)
+			tt.validate(t, doc)
+		})
+	}
+}
+
+func TestParseInlines(t *testing.T) {
+	content := "# **Week** 42\n\nRead [[Atomic Habits|the book]] and _skim_ `notes`, see [site](https://example.com \"Home\").\n\n- [ ] ![[Gym plan]] **Gym**"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	heading := markdown.FindHeadings(doc)[0]
+	assert.Equal(t, "**Week** 42", heading.Title)
+	assert.Len(t, heading.Inlines, 2)
+	strong, ok := heading.Inlines[0].(*markdown.Strong)
+	assert.True(t, ok)
+	assert.Equal(t, "**", strong.Delimiter)
+	assert.Equal(t, "Week 42", markdown.PlainText(heading.Inlines))
+
+	para := heading.Children()[0].(*markdown.Paragraph)
+	var types []markdown.NodeType
+	for _, inline := range para.Inlines {
+		types = append(types, inline.Type())
+	}
+	assert.Equal(t, []markdown.NodeType{
+		markdown.NodeText, markdown.NodeWikiLink, markdown.NodeText, markdown.NodeEmphasis,
+		markdown.NodeText, markdown.NodeCodeSpan, markdown.NodeText, markdown.NodeLink, markdown.NodeText,
+	}, types)
+
+	wiki := para.Inlines[1].(*markdown.WikiLink)
+	assert.Equal(t, "Atomic Habits", wiki.Target)
+	assert.Equal(t, "the book", wiki.Alias)
+	assert.False(t, wiki.Embed)
+
+	emphasis := para.Inlines[3].(*markdown.Emphasis)
+	assert.Equal(t, "_", emphasis.Delimiter)
+
+	link := para.Inlines[7].(*markdown.Link)
+	assert.Equal(t, "https://example.com", link.Destination)
+	assert.Equal(t, "Home", link.Title)
+	assert.Equal(t, "site", markdown.PlainText(link.Children()))
+
+	task := markdown.FindTasks(doc)[0]
+	assert.Equal(t, "![[Gym plan]] **Gym**", task.Content)
+	embed, ok := task.Inlines[0].(*markdown.WikiLink)
+	assert.True(t, ok)
+	assert.True(t, embed.Embed)
+	assert.Equal(t, "Gym plan", embed.Target)
+}
+
+func TestParseBlocks(t *testing.T) {
+	content := "# Week 42\n\n---\n\n```go title=main.go\nfmt.Println()\n```\n\n> Quoted *text*\n> - [ ] Quoted task\n\n* * *\n\n    indented code"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	week := markdown.FindHeadingByTitle(doc, "Week 42")
+	children := week.Children()
+	assert.Len(t, children, 5)
+
+	rule, ok := children[0].(*markdown.ThematicBreak)
+	assert.True(t, ok)
+	assert.Equal(t, "---", rule.Marker)
+
+	code, ok := children[1].(*markdown.CodeBlock)
+	assert.True(t, ok)
+	assert.True(t, code.Fenced)
+	assert.Equal(t, "```", code.Fence)
+	assert.Equal(t, "go", code.Language)
+	assert.Equal(t, "go title=main.go", code.Info)
+	assert.Equal(t, "fmt.Println()", code.Content)
+	span, _ := code.Span()
+	assert.Equal(t, "```go title=main.go\nfmt.Println()\n```", content[span.Start:span.End])
+
+	quote, ok := children[2].(*markdown.Blockquote)
+	assert.True(t, ok)
+	assert.Len(t, quote.Children(), 2)
+	assert.Equal(t, "Quoted *text*", quote.Children()[0].(*markdown.Paragraph).Content)
+	assert.Len(t, markdown.FindTasks(quote), 1)
+
+	assert.Equal(t, "* * *", children[3].(*markdown.ThematicBreak).Marker)
+
+	indented, ok := children[4].(*markdown.CodeBlock)
+	assert.True(t, ok)
+	assert.False(t, indented.Fenced)
+	assert.Equal(t, "indented code", indented.Content)
+}
+
+func TestParseRawBlocks(t *testing.T) {
+	content := "# Week 42\n\n<details>\n<summary>Log</summary>\n\n- [ ] Hidden\n</details>\n\nSee [the docs][docs] and the note[^1].\n\n[docs]: https://example.com\n  \"Docs\"\n[^1]: A footnote\n\n<!--\ncomment\n-->"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	children := markdown.FindHeadingByTitle(doc, "Week 42").Children()
+	assert.Len(t, children, 7)
+
+	// HTML blocks end at a blank line, so the list inside the details
+	// element is ordinary markdown
+	expected := []struct {
+		kind    string
+		content string
+	}{
+		{markdown.RawHTML, "<details>\n<summary>Log</summary>"},
+		{},
+		{markdown.RawHTML, "</details>"},
+		{},
+		{markdown.RawLinkReference, "[docs]: https://example.com\n  \"Docs\""},
+		{markdown.RawFootnote, "[^1]: A footnote"},
+		{markdown.RawHTML, "<!--\ncomment\n-->"},
+	}
+	for i, e := range expected {
+		raw, ok := children[i].(*markdown.RawBlock)
+		if e.kind == "" {
+			assert.False(t, ok)
+			continue
+		}
+		if assert.True(t, ok, "child %d", i) {
+			assert.Equal(t, e.kind, raw.Kind)
+			assert.Equal(t, e.content, raw.Content)
+			span, _ := raw.Span()
+			assert.Equal(t, e.content, content[span.Start:span.End])
+		}
+	}
+}
+
+func TestParseCallouts(t *testing.T) {
+	content := "## Review\n\n> [!note] What went *well*\n> Gym three times.\n>\n> - [x] Ship #planner\n\n> [!warning]-\n> Sleep slipped\n\n> Just a quote"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	callouts := markdown.FindCallouts(doc)
+	assert.Len(t, callouts, 2)
+
+	note := callouts[0]
+	assert.Equal(t, "note", note.Kind)
+	assert.Equal(t, "What went *well*", note.Title)
+	assert.Equal(t, markdown.FoldNone, note.Fold)
+	assert.Equal(t, "What went well", markdown.PlainText(note.Inlines))
+	assert.Len(t, note.Children(), 2)
+	assert.Equal(t, "Gym three times.", note.Children()[0].(*markdown.Paragraph).Content)
+	assert.Len(t, markdown.FindTasks(note), 1)
+	assert.Len(t, markdown.FindTags(note), 1)
+
+	span, _ := note.Children()[0].Span()
+	assert.Equal(t, "> Gym three times.", content[span.Start:span.End])
+	own, _ := note.OwnSpan()
+	assert.Equal(t, "> [!note] What went *well*", content[own.Start:own.End])
+
+	warning := callouts[1]
+	assert.Equal(t, "warning", warning.Kind)
+	assert.Equal(t, "", warning.Title)
+	assert.Equal(t, markdown.FoldCollapsed, warning.Fold)
+	assert.Equal(t, "Sleep slipped", warning.Children()[0].(*markdown.Paragraph).Content)
+
+	review := markdown.FindHeadingByTitle(doc, "Review")
+	assert.IsType(t, &markdown.Blockquote{}, review.Children()[2])
+}
+
+func TestParseBlockIDs(t *testing.T) {
+	content := "- [ ] Gym 📅 2026-10-20 ^gym\n- Read *slowly* ^read-1\n\nA note ^note\n\n^standalone\n\nNot an id^here"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	gym, ok := markdown.FindByBlockID(doc, "gym").(*markdown.Task)
+	assert.True(t, ok)
+	assert.Equal(t, "Gym 📅 2026-10-20", gym.Content)
+	assert.Equal(t, "Gym", gym.Description())
+	assert.Equal(t, 20, gym.Metadata.Due.Day())
+	assert.Equal(t, "Gym 📅 2026-10-20", markdown.PlainText(gym.Inlines))
+
+	read, ok := markdown.FindByBlockID(doc, "^read-1").(*markdown.ListItem)
+	assert.True(t, ok)
+	assert.Equal(t, "Read *slowly*", read.Content)
+	assert.Equal(t, "Read slowly", markdown.PlainText(read.Inlines))
+
+	note, ok := markdown.FindByBlockID(doc, "note").(*markdown.Paragraph)
+	assert.True(t, ok)
+	assert.Equal(t, "A note", note.Content)
+
+	// An ID must follow the text after a space
+	paragraphs := doc.Children()
+	assert.Equal(t, "^standalone", paragraphs[2].(*markdown.Paragraph).Content)
+	assert.Equal(t, "Not an id^here", paragraphs[3].(*markdown.Paragraph).Content)
+	assert.Equal(t, "", paragraphs[3].(*markdown.Paragraph).BlockID)
+}
+
+func TestParseLineEndings(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		lineEnding string
+		bom        bool
+		trailing   string
+	}{
+		{name: "LF", content: "# Week 42\n\n- [ ] Gym\n", trailing: "\n"},
+		{name: "CRLF", content: "# Week 42\r\n\r\n- [ ] Gym", lineEnding: markdown.CRLF},
+		{name: "BOM", content: "\uFEFF# Week 42\r\n\r\n- [ ] Gym\r\n", lineEnding: markdown.CRLF, bom: true, trailing: "\r\n"},
+		{name: "Single line", content: "# Week 42"},
+		{name: "Trailing blank lines", content: "# Week 42\n\n- [ ] Gym  \n\n  \n", trailing: "\n\n  \n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseMarkdown(tt.content)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.lineEnding, doc.LineEnding)
+			assert.Equal(t, tt.bom, doc.BOM)
+			assert.Equal(t, tt.trailing, doc.Trailing)
+
+			// The tree is the same whatever the file used
+			assert.Equal(t, "Week 42", doc.Children()[0].(*markdown.Heading).Title)
+			assert.NotContains(t, string(doc.Source()), "\r")
+		})
+	}
+
+	// Line endings that differ from the first line's are recorded
+	doc, err := ParseMarkdown("# Week 42\r\n\r\n- [ ] Gym\n- [ ] Read\r\n")
+	assert.NoError(t, err)
+	assert.Equal(t, markdown.CRLF, doc.LineEnding)
+	assert.Equal(t, markdown.CRLF, doc.LineEndingAt(9))
+	assert.Equal(t, markdown.LF, doc.LineEndingAt(20))
+}
+
+func TestParseHeadingStyles(t *testing.T) {
+	content := "Week 42\n=======\n\nHabits {#habits}\n------\n\n- [ ] Gym\n\n## Notes {#my-notes .wide}\n\n### Notes\n\n# Week 42"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	headings := markdown.FindHeadings(doc)
+	assert.Len(t, headings, 5)
+
+	week := headings[0]
+	assert.Equal(t, 1, week.Level)
+	assert.Equal(t, "Week 42", week.Title)
+	assert.True(t, week.Setext)
+	assert.Equal(t, "", week.ID)
+	assert.Equal(t, "week-42", week.AutoID)
+	ownSpan, _ := week.OwnSpan()
+	assert.Equal(t, "Week 42\n=======", content[ownSpan.Start:ownSpan.End])
+
+	// Attributes are not part of the title
+	habits := headings[1]
+	assert.Equal(t, 2, habits.Level)
+	assert.Equal(t, "Habits", habits.Title)
+	assert.Equal(t, "Habits", markdown.PlainText(habits.Inlines))
+	assert.True(t, habits.Setext)
+	assert.Equal(t, "habits", habits.ID)
+	assert.Equal(t, "", habits.AutoID)
+
+	notes := headings[2]
+	assert.Equal(t, "Notes", notes.Title)
+	assert.False(t, notes.Setext)
+	assert.Equal(t, "my-notes", notes.ID)
+	assert.Equal(t, ".wide", notes.Attributes)
+
+	// Generated IDs are unique within the document
+	assert.Equal(t, "notes", headings[3].AutoID)
+	assert.Equal(t, "week-42-1", headings[4].AutoID)
+	assert.Same(t, habits, markdown.FindHeadingByID(doc, "#habits"))
+	assert.Same(t, headings[4], markdown.FindHeadingByID(doc, "week-42-1"))
+}
+
+func TestParseRichListItems(t *testing.T) {
+	content := "- [ ] Plan trip\ncheck dates\n\n  Book a hotel  \n  near the station\n\n  ```sh\n  ls -la\n  ```\n\n  - [ ] Pack\n- ```go\n  code\n  ```\n- > Quoted"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	list := doc.Children()[0].(*markdown.List)
+	assert.Len(t, list.Children(), 3)
+
+	// A lazy continuation line belongs to the task's text
+	trip := list.Children()[0].(*markdown.Task)
+	assert.Equal(t, "Plan trip\ncheck dates", trip.Content)
+
+	blocks := trip.Children()
+	assert.Len(t, blocks, 3)
+	assert.Equal(t, "Book a hotel  \nnear the station", blocks[0].(*markdown.Paragraph).Content)
+	assert.Equal(t, "ls -la", blocks[1].(*markdown.CodeBlock).Content)
+	assert.Equal(t, "Pack", trip.Subtasks()[0].Content)
+
+	// Items can start with a block other than a paragraph
+	code := list.Children()[1].(*markdown.ListItem)
+	assert.Equal(t, "", code.Content)
+	assert.Equal(t, "code", code.Children()[0].(*markdown.CodeBlock).Content)
+	span, _ := code.Children()[0].Span()
+	assert.Equal(t, "- ```go\n  code\n  ```", content[span.Start:span.End])
+
+	quote := list.Children()[2].(*markdown.ListItem)
+	assert.Equal(t, "Quoted", quote.Children()[0].Children()[0].(*markdown.Paragraph).Content)
+}
+
+func TestParseTable(t *testing.T) {
+	content := "# Habits\n\n| Habit | Days |\n|:------|-----:|\n| **Gym** | 3 |\n| Read \\| write |\n\n| Empty |\n| --- |"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	children := markdown.FindHeadingByTitle(doc, "Habits").Children()
+	assert.Len(t, children, 2)
+
+	table, ok := children[0].(*markdown.Table)
+	assert.True(t, ok)
+	assert.Equal(t, []markdown.Alignment{markdown.AlignLeft, markdown.AlignRight}, table.Alignments)
+
+	rows := table.Rows()
+	assert.Len(t, rows, 3)
+	assert.True(t, rows[0].Header)
+	assert.False(t, rows[1].Header)
+
+	var cells [][]string
+	for _, row := range rows {
+		var texts []string
+		for _, cell := range row.Cells() {
+			texts = append(texts, cell.Content)
+		}
+		cells = append(cells, texts)
+	}
+	assert.Equal(t, [][]string{{"Habit", "Days"}, {"**Gym**", "3"}, {"Read \\| write", ""}}, cells)
+
+	gym := rows[1].Cells()[0]
+	assert.Len(t, gym.Inlines, 1)
+	assert.Equal(t, markdown.NodeStrong, gym.Inlines[0].Type())
+
+	// The delimiter row is part of a table without body rows
+	empty := children[1].(*markdown.Table)
+	assert.Equal(t, []markdown.Alignment{markdown.AlignNone}, empty.Alignments)
+	span, _ := empty.Span()
+	assert.Equal(t, "| Empty |\n| --- |", content[span.Start:span.End])
+}
+
+func TestParseTaskStatuses(t *testing.T) {
+	content := "- [ ] Todo\n- [x] Done\n- [/] In *progress*\n- [-] Cancelled\n- [>] Migrated\n- [<] Scheduled\n- [?] Question\n- [] Empty"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	tasks := markdown.FindTasks(doc)
+	var statuses []markdown.TaskStatus
+	for _, task := range tasks {
+		statuses = append(statuses, task.Status)
+	}
+	assert.Equal(t, []markdown.TaskStatus{
+		markdown.StatusTodo,
+		markdown.StatusDone,
+		markdown.StatusInProgress,
+		markdown.StatusCancelled,
+		markdown.StatusMigrated,
+		markdown.StatusScheduled,
+	}, statuses)
+
+	// The checkbox is not part of the task's content or inlines
+	progress := tasks[2]
+	assert.Equal(t, "In *progress*", progress.Content)
+	assert.Equal(t, "In progress", markdown.PlainText(progress.Inlines))
+
+	// Unrecognised characters leave the item as plain list item
+	items := doc.Children()[0].Children()
+	assert.Equal(t, "[?] Question", items[6].(*markdown.ListItem).Content)
+
+	// The recognised characters can be configured
+	statusSet := markdown.DefaultTaskStatuses()
+	statusSet['?'] = "question"
+	delete(statusSet, markdown.StatusMigrated)
+	doc, err = ParseMarkdown(content, WithTaskStatuses(statusSet))
+	assert.NoError(t, err)
+
+	tasks = markdown.FindTasks(doc)
+	assert.Len(t, tasks, 6)
+	assert.Equal(t, markdown.TaskStatus('?'), tasks[5].Status)
+	assert.Equal(t, "Question", tasks[5].Content)
+}
+
+func TestParseLinksAndTags(t *testing.T) {
+	content := "#weekly review of [[Atomic Habits#Chapter 1|chapter one]] and [[#Habits]]\n\n- [ ] Gym #health #2024 x#notatag\n- [ ] Plan ![[Board#^q3]] #project/planner"
+
+	doc, err := ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	links := markdown.FindLinks(doc)
+	assert.Len(t, links, 3)
+	assert.Equal(t, "Atomic Habits", links[0].Target)
+	assert.Equal(t, "Chapter 1", links[0].Heading)
+	assert.Equal(t, "chapter one", links[0].Alias)
+	assert.Equal(t, "", links[1].Target)
+	assert.Equal(t, "Habits", links[1].Heading)
+	assert.Equal(t, "Board", links[2].Target)
+	assert.Equal(t, "q3", links[2].Block)
+	assert.True(t, links[2].Embed)
+
+	var names []string
+	for _, tag := range markdown.FindTags(doc) {
+		names = append(names, tag.Name)
+	}
+	assert.Equal(t, []string{"weekly", "health", "project/planner"}, names)
+
+	tasks := markdown.FindTasks(doc)
+	assert.Len(t, markdown.FindTags(tasks[0]), 1)
+	assert.Equal(t, "Gym #health #2024 x#notatag", tasks[0].Content)
+}
@@ -0,0 +1,348 @@
+package reader
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// computeSpans records the line-aligned source range of every block in the
+// AST. Blocks are visited in document order so that blocks goldmark keeps no
+// lines for, such as thematic breaks and code fences, can be located by
+// scanning forward from the end of the previous block.
+func computeSpans(doc ast.Node, source []byte) map[ast.Node]markdown.Span {
+	spans := map[ast.Node]markdown.Span{}
+	cursor := 0
+	for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+		blockSpans(child, source, &cursor, spans)
+	}
+	return spans
+}
+
+// blockSpans computes the span of node and its descendants. A container's
+// span is the union of its own lines and those of its children.
+func blockSpans(node ast.Node, source []byte, cursor *int, spans map[ast.Node]markdown.Span) (markdown.Span, bool) {
+	if node.Type() != ast.TypeBlock {
+		return markdown.Span{}, false
+	}
+
+	var span markdown.Span
+	var ok bool
+	switch n := node.(type) {
+	case *ast.ThematicBreak:
+		span, ok = findLine(source, *cursor, isThematicBreak)
+	case *ast.FencedCodeBlock:
+		span, ok = fencedCodeSpan(n, source, *cursor)
+	case *ast.Heading:
+		span, ok = linesSpan(node, source)
+		if ok && isSetextHeading(n, source) {
+			// The underline is not one of the heading's lines
+			if underline, found := lineAt(source, nextLine(source, span.End)); found {
+				span.End = underline.End
+			}
+		}
+	case *ast.HTMLBlock:
+		span, ok = linesSpan(node, source)
+		if n.HasClosure() {
+			closure := markdown.Span{Start: lineStart(source, n.ClosureLine.Start), End: lineEnd(source, n.ClosureLine.Stop)}
+			if !ok {
+				span, ok = closure, true
+			}
+			span.End = closure.End
+		}
+	default:
+		span, ok = linesSpan(node, source)
+	}
+	if ok && span.End >= *cursor {
+		*cursor = nextLine(source, span.End)
+	}
+
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		childSpan, childOK := blockSpans(child, source, cursor, spans)
+		if !childOK {
+			continue
+		}
+		if !ok {
+			span, ok = childSpan, true
+			continue
+		}
+		if childSpan.Start < span.Start {
+			span.Start = childSpan.Start
+		}
+		if childSpan.End > span.End {
+			span.End = childSpan.End
+		}
+	}
+
+	// The delimiter row belongs to no cell, so a table without body rows
+	// would otherwise end on its header
+	if _, isTable := node.(*extast.Table); isTable && ok && node.ChildCount() == 1 {
+		if delimiter, found := lineAt(source, nextLine(source, span.End)); found {
+			span.End = delimiter.End
+			*cursor = nextLine(source, span.End)
+		}
+	}
+
+	if ok {
+		spans[node] = span
+	}
+	return span, ok
+}
+
+// definitionSpans returns the spans of the link reference and footnote
+// definitions at the top level of the document. goldmark drops these from
+// the AST, so they are found in the lines no top-level block covers.
+func definitionSpans(doc ast.Node, source []byte, spans map[ast.Node]markdown.Span) []markdown.Span {
+	var covered []markdown.Span
+	for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+		if span, ok := spans[child]; ok {
+			covered = append(covered, span)
+		}
+	}
+	sort.Slice(covered, func(i, j int) bool { return covered[i].Start < covered[j].Start })
+
+	var definitions []markdown.Span
+	var current *markdown.Span
+	for pos := 0; pos < len(source); {
+		if len(covered) > 0 && pos >= covered[0].Start {
+			if next := nextLine(source, covered[0].End); next > pos {
+				pos = next
+			}
+			covered = covered[1:]
+			current = nil
+			continue
+		}
+		line, _ := lineAt(source, pos)
+		text := string(source[line.Start:line.End])
+		switch {
+		case strings.TrimSpace(text) == "":
+			current = nil
+		case current != nil:
+			// Titles may continue on the following lines
+			current.End = line.End
+		case definitionRegex.MatchString(text):
+			definitions = append(definitions, line)
+			current = &definitions[len(definitions)-1]
+		}
+		pos = nextLine(source, line.End)
+	}
+	return definitions
+}
+
+// linesSpan returns the span covered by a block's own lines
+func linesSpan(node ast.Node, source []byte) (markdown.Span, bool) {
+	lines := node.Lines()
+	if lines.Len() == 0 {
+		return markdown.Span{}, false
+	}
+
+	start, stop := lines.At(0).Start, lines.At(lines.Len()-1).Stop
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		if segment.Start < start {
+			start = segment.Start
+		}
+		if segment.Stop > stop {
+			stop = segment.Stop
+		}
+	}
+	return markdown.Span{Start: lineStart(source, start), End: lineEnd(source, stop)}, true
+}
+
+// fencedCodeSpan returns the span of a fenced code block including its
+// opening and closing fences
+func fencedCodeSpan(node *ast.FencedCodeBlock, source []byte, cursor int) (markdown.Span, bool) {
+	var open markdown.Span
+	lines := node.Lines()
+	switch {
+	case node.Info != nil:
+		start := lineStart(source, node.Info.Segment.Start)
+		open = markdown.Span{Start: start, End: lineEnd(source, start+1)}
+	case lines.Len() > 0:
+		first := lineStart(source, lines.At(0).Start)
+		if first == 0 {
+			return markdown.Span{}, false
+		}
+		start := lineStart(source, first-1)
+		open = markdown.Span{Start: start, End: lineEnd(source, start+1)}
+	default:
+		var ok bool
+		if open, ok = findLine(source, cursor, func(line string) bool { return fenceMarker([]byte(line)) != "" }); !ok {
+			return markdown.Span{}, false
+		}
+	}
+
+	span := open
+	if lines.Len() > 0 {
+		span.End = lineEnd(source, lines.At(lines.Len()-1).Stop)
+	}
+
+	// The closing fence is optional; an unterminated block runs to the end
+	// of its container
+	fence := fenceMarker(source[open.Start:open.End])
+	if close, ok := lineAt(source, nextLine(source, span.End)); ok && fence != "" {
+		if marker := fenceMarker(source[close.Start:close.End]); marker != "" && marker[0] == fence[0] && len(marker) >= len(fence) {
+			span.End = close.End
+		}
+	}
+	return span, true
+}
+
+// containerPrefixRegex matches the indentation, blockquote markers and list
+// markers before the first block on a line
+var containerPrefixRegex = regexp.MustCompile(`^(?:[ \t>]|[-+*][ \t]|[0-9]{1,9}[.)][ \t])*`)
+
+// fenceMarker returns the run of backticks or tildes that opens or closes a
+// fenced code block on line, or "" if line is not a fence. The opening fence
+// of a list item's first block follows the item's marker.
+func fenceMarker(line []byte) string {
+	trimmed := string(line[len(containerPrefixRegex.Find(line)):])
+	if trimmed == "" || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == trimmed[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// isSetextHeading reports whether a heading is underlined rather than
+// prefixed with "#"s, which goldmark does not record. An ATX heading's text
+// starts after its "#"s on the same line.
+func isSetextHeading(node *ast.Heading, source []byte) bool {
+	lines := node.Lines()
+	if lines.Len() == 0 {
+		return false
+	}
+	start := lines.At(0).Start
+	return bytes.IndexByte(source[lineStart(source, start):start], '#') < 0
+}
+
+// isThematicBreak reports whether line is a thematic break such as --- or * * *
+func isThematicBreak(line string) bool {
+	trimmed := strings.TrimLeft(line, " >\t")
+	if trimmed == "" {
+		return false
+	}
+	marker := trimmed[0]
+	if marker != '-' && marker != '*' && marker != '_' {
+		return false
+	}
+	count := 0
+	for i := 0; i < len(trimmed); i++ {
+		switch trimmed[i] {
+		case marker:
+			count++
+		case ' ', '\t', '\r':
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+// findLine returns the span of the first line at or after from that
+// satisfies match
+func findLine(source []byte, from int, match func(string) bool) (markdown.Span, bool) {
+	for pos := from; pos < len(source); pos = nextLine(source, pos) {
+		line, _ := lineAt(source, pos)
+		if match(string(source[line.Start:line.End])) {
+			return line, true
+		}
+		if line.End >= len(source) {
+			break
+		}
+	}
+	return markdown.Span{}, false
+}
+
+// lineAt returns the span of the line starting at pos
+func lineAt(source []byte, pos int) (markdown.Span, bool) {
+	if pos >= len(source) {
+		return markdown.Span{}, false
+	}
+	return markdown.Span{Start: pos, End: lineEnd(source, pos+1)}, true
+}
+
+// nextLine returns the offset of the line following the one containing pos
+func nextLine(source []byte, pos int) int {
+	if i := bytes.IndexByte(source[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(source)
+}
+
+// lineStart returns the offset of the beginning of the line containing pos
+func lineStart(source []byte, pos int) int {
+	return bytes.LastIndexByte(source[:pos], '\n') + 1
+}
+
+// lineEnd returns the offset of the end of the line containing the byte
+// before stop, excluding the line ending
+func lineEnd(source []byte, stop int) int {
+	if stop > 0 && source[stop-1] == '\n' {
+		stop--
+	} else if i := bytes.IndexByte(source[stop:], '\n'); i >= 0 {
+		stop += i
+	} else {
+		stop = len(source)
+	}
+	if stop > 0 && source[stop-1] == '\r' {
+		stop--
+	}
+	return stop
+}
+
+// lineIndex converts byte offsets in a source into line and column positions
+type lineIndex struct {
+	source []byte
+	starts []int // offset of the beginning of each line
+}
+
+func newLineIndex(source []byte) *lineIndex {
+	starts := []int{0}
+	for i, c := range source {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &lineIndex{source: source, starts: starts}
+}
+
+// position returns the line and column of offset
+func (l *lineIndex) position(offset int) markdown.Position {
+	line := sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset }) - 1
+	column := utf8.RuneCount(l.source[l.starts[line]:offset]) + 1
+	return markdown.Position{Line: line + 1, Column: column}
+}
+
+// spanRange converts a line-aligned span into a range starting at the first
+// non-blank character of its first line
+func (l *lineIndex) spanRange(span markdown.Span) markdown.Range {
+	start := span.Start
+	for start < span.End && (l.source[start] == ' ' || l.source[start] == '\t') {
+		start++
+	}
+	return markdown.Range{Start: l.position(start), End: l.position(span.End)}
+}
+
+// setRanges records the line and column range of node and every block
+// beneath it from their spans
+func setRanges(node markdown.Node, lines *lineIndex) {
+	if span, ok := node.Span(); ok {
+		node.SetRange(lines.spanRange(span))
+	}
+	for _, child := range node.Children() {
+		setRanges(child, lines)
+	}
+}
@@ -0,0 +1,455 @@
+package markdown
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Selector is a compiled path query over a markdown tree. A selector is a
+// sequence of steps separated by combinators:
+//
+//	# Week 42 > ## Habits > task[checked=false]
+//
+// A step is either a heading written as markdown ("## Habits", matched by
+// level and case-insensitive title, with "*" matching any title) or a node
+// type such as task, paragraph or list_item ("*" matches any type; other
+// names, including inline types, are an error), followed by optional
+// attribute filters:
+//
+//	[name=value]   an attribute equals value (case-insensitive)
+//	[name!=value]  no value of the attribute equals value
+//	[name*=value]  an attribute contains value
+//	[name]         the attribute is present and not empty or false
+//
+// Values containing spaces or brackets can be quoted. Attributes are the
+// node's exported fields (level, title, content, ordered, language...) plus
+// type, text (inline content as plain text), tag (any #tag in the inline
+// content), and for tasks checked, status (character, or name from
+// DefaultTaskStatuses unless WithTaskStatuses is used) and description.
+//
+// "A > B" matches B directly within A, looking through lists, so the tasks
+// of a heading's list are within the heading but subtasks are within their
+// parent task. "A >> B" matches B anywhere beneath A. The first step matches
+// anywhere beneath the node being searched.
+type Selector struct {
+	steps    []selectorStep
+	statuses TaskStatusSet
+}
+
+// selectorStep is one step of a selector along with the combinator that
+// joins it to the previous step
+type selectorStep struct {
+	descendant bool
+
+	nodeType NodeType // empty matches any type
+	level    int      // heading level, or 0
+	title    string   // heading title, or empty for any
+	filters  []selectorFilter
+}
+
+// selectorFilter is an attribute filter such as [checked=false]
+type selectorFilter struct {
+	name  string
+	op    string // "=", "!=", "*=" or "" for presence
+	value string
+}
+
+// CompileSelector parses a selector
+func CompileSelector(selector string) (*Selector, error) {
+	parts, combinators, err := splitSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+
+	s := &Selector{}
+	for i, part := range parts {
+		step, err := parseStep(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		if i > 0 {
+			step.descendant = combinators[i-1] == ">>"
+		}
+		s.steps = append(s.steps, step)
+	}
+	return s, nil
+}
+
+// Select returns the nodes beneath root matching a selector, in document
+// order
+func Select(root Node, selector string) ([]Node, error) {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(root), nil
+}
+
+// WithTaskStatuses sets the statuses whose names status filters match, so
+// that a file parsed with reader.WithTaskStatuses can be queried by its own
+// names, and returns the selector
+func (s *Selector) WithTaskStatuses(statuses TaskStatusSet) *Selector {
+	s.statuses = statuses
+	return s
+}
+
+// Select returns the nodes beneath root matching the selector, in document
+// order
+func (s *Selector) Select(root Node) []Node {
+	if len(s.steps) == 0 {
+		return nil
+	}
+	statuses := s.statuses
+	if statuses == nil {
+		statuses = DefaultTaskStatuses()
+	}
+
+	current := filterNodes(descendants(root), s.steps[0], statuses)
+	for _, step := range s.steps[1:] {
+		var candidates []Node
+		for _, node := range current {
+			if step.descendant {
+				candidates = append(candidates, descendants(node)...)
+			} else {
+				candidates = append(candidates, scopeChildren(node)...)
+			}
+		}
+		current = filterNodes(candidates, step, statuses)
+	}
+
+	// Nested matches can be reached more than once, so restore document
+	// order without duplicates
+	matched := map[Node]bool{}
+	for _, node := range current {
+		matched[node] = true
+	}
+	var result []Node
+	for _, node := range descendants(root) {
+		if matched[node] {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// filterNodes returns the nodes matching a step
+func filterNodes(nodes []Node, step selectorStep, statuses TaskStatusSet) []Node {
+	var matches []Node
+	for _, node := range nodes {
+		if step.matches(node, statuses) {
+			matches = append(matches, node)
+		}
+	}
+	return matches
+}
+
+// descendants returns every block node beneath node in document order
+func descendants(node Node) []Node {
+	var nodes []Node
+	for _, child := range node.Children() {
+		nodes = append(nodes, child)
+		nodes = append(nodes, descendants(child)...)
+	}
+	return nodes
+}
+
+// scopeChildren returns the children of node, looking through lists to the
+// items they hold
+func scopeChildren(node Node) []Node {
+	var nodes []Node
+	for _, child := range node.Children() {
+		if _, ok := child.(*List); ok {
+			nodes = append(nodes, scopeChildren(child)...)
+			continue
+		}
+		nodes = append(nodes, child)
+	}
+	return nodes
+}
+
+func (step selectorStep) matches(node Node, statuses TaskStatusSet) bool {
+	if step.nodeType != "" && node.Type() != step.nodeType {
+		return false
+	}
+	if step.level > 0 {
+		heading, ok := node.(*Heading)
+		if !ok || heading.Level != step.level {
+			return false
+		}
+		if step.title != "" && !strings.EqualFold(strings.TrimSpace(heading.Title), step.title) {
+			return false
+		}
+	}
+	for _, filter := range step.filters {
+		if !filter.matches(node, statuses) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f selectorFilter) matches(node Node, statuses TaskStatusSet) bool {
+	values := attributeValues(node, f.name, statuses)
+	switch f.op {
+	case "":
+		for _, value := range values {
+			if value != "" && value != "false" {
+				return true
+			}
+		}
+		return false
+	case "!=":
+		for _, value := range values {
+			if strings.EqualFold(value, f.value) {
+				return false
+			}
+		}
+		return true
+	case "*=":
+		for _, value := range values {
+			if strings.Contains(strings.ToLower(value), strings.ToLower(f.value)) {
+				return true
+			}
+		}
+		return false
+	default:
+		for _, value := range values {
+			if strings.EqualFold(value, f.value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// attributeValues returns the values of a node's attribute, which may be
+// several for attributes such as tag. Status names are looked up in
+// statuses.
+func attributeValues(node Node, name string, statuses TaskStatusSet) []string {
+	switch name {
+	case "type":
+		return []string{string(node.Type())}
+	case "text":
+		if container, ok := node.(InlineContainer); ok {
+			return []string{PlainText(container.InlineNodes())}
+		}
+		return nil
+	case "tag":
+		container, ok := node.(InlineContainer)
+		if !ok {
+			return nil
+		}
+		var tags []string
+		for _, inline := range container.InlineNodes() {
+			for _, tag := range FindTags(inline) {
+				tags = append(tags, tag.Name)
+			}
+		}
+		return tags
+	}
+
+	if task, ok := node.(*Task); ok {
+		switch name {
+		case "checked":
+			return []string{strconv.FormatBool(task.Checked())}
+		case "status":
+			return []string{task.Status.String(), statuses.Name(task.Status)}
+		case "description":
+			return []string{task.Description()}
+		}
+	}
+
+	// Otherwise compare against an exported field of the same name
+	v := reflect.ValueOf(node)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.FieldByNameFunc(func(field string) bool {
+		return strings.EqualFold(field, strings.ReplaceAll(name, "_", ""))
+	})
+	if !field.IsValid() || !field.CanInterface() {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int:
+		return []string{fmt.Sprint(field.Interface())}
+	}
+	return nil
+}
+
+// splitSelector splits a selector into its steps and the combinators
+// between them, ignoring ">" inside brackets or quotes
+func splitSelector(selector string) ([]string, []string, error) {
+	var parts, combinators []string
+	var quote byte
+	depth := 0
+	start := 0
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '>' && depth == 0:
+			combinator := ">"
+			if i+1 < len(selector) && selector[i+1] == '>' {
+				combinator = ">>"
+			}
+			parts = append(parts, strings.TrimSpace(selector[start:i]))
+			combinators = append(combinators, combinator)
+			i += len(combinator) - 1
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, nil, fmt.Errorf("unterminated quote")
+	}
+	if depth != 0 {
+		return nil, nil, fmt.Errorf("unbalanced brackets")
+	}
+	parts = append(parts, strings.TrimSpace(selector[start:]))
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, nil, fmt.Errorf("empty step")
+		}
+	}
+	return parts, combinators, nil
+}
+
+// parseStep parses a single step such as "## Habits" or "task[checked=false]"
+func parseStep(part string) (selectorStep, error) {
+	var step selectorStep
+
+	if strings.HasPrefix(part, "#") {
+		level := len(part) - len(strings.TrimLeft(part, "#"))
+		if level > 6 {
+			return step, fmt.Errorf("heading level %d in %q", level, part)
+		}
+		title := strings.TrimSpace(part[level:])
+		if unquoted, err := strconv.Unquote(title); err == nil {
+			title = unquoted
+		}
+		if title == "*" {
+			title = ""
+		}
+		step.nodeType = NodeHeading
+		step.level = level
+		step.title = title
+		return step, nil
+	}
+
+	name := part
+	if i := strings.IndexByte(part, '['); i >= 0 {
+		name = part[:i]
+		filters, err := parseFilters(part[i:])
+		if err != nil {
+			return step, err
+		}
+		step.filters = filters
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return step, fmt.Errorf("missing node type in %q", part)
+	}
+	if name != "*" {
+		step.nodeType = NodeType(name)
+		if !selectableTypes[step.nodeType] {
+			return step, fmt.Errorf("unknown node type %q", name)
+		}
+	}
+	return step, nil
+}
+
+// selectableTypes are the types of the block nodes a selector can match
+var selectableTypes = map[NodeType]bool{
+	NodeHeading:       true,
+	NodeParagraph:     true,
+	NodeTask:          true,
+	NodeList:          true,
+	NodeListItem:      true,
+	NodeText:          true,
+	NodeCodeBlock:     true,
+	NodeBlockquote:    true,
+	NodeCallout:       true,
+	NodeThematicBreak: true,
+	NodeTable:         true,
+	NodeTableRow:      true,
+	NodeTableCell:     true,
+	NodeRawBlock:      true,
+}
+
+// parseFilters parses a run of attribute filters such as
+// [checked=false][tag=health]
+func parseFilters(text string) ([]selectorFilter, error) {
+	var filters []selectorFilter
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		if text[0] != '[' {
+			return nil, fmt.Errorf("unexpected %q", text)
+		}
+		end := closingBracket(text)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated filter %q", text)
+		}
+		filter, err := parseFilter(text[1:end])
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+		text = text[end+1:]
+	}
+	return filters, nil
+}
+
+// closingBracket returns the index of the "]" closing the filter at the
+// start of text, skipping over quoted values
+func closingBracket(text string) int {
+	var quote bool
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote && text[i] == '\\':
+			i++
+		case text[i] == '"':
+			quote = !quote
+		case !quote && text[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseFilter parses the inside of an attribute filter
+func parseFilter(text string) (selectorFilter, error) {
+	filter := selectorFilter{name: strings.TrimSpace(text)}
+	if i := strings.IndexByte(text, '='); i >= 0 {
+		name := text[:i]
+		filter.op = "="
+		if strings.HasSuffix(name, "!") || strings.HasSuffix(name, "*") {
+			filter.op = name[len(name)-1:] + "="
+			name = name[:len(name)-1]
+		}
+		filter.name = strings.TrimSpace(name)
+		filter.value = strings.TrimSpace(text[i+1:])
+		if unquoted, err := strconv.Unquote(filter.value); err == nil {
+			filter.value = unquoted
+		}
+	}
+	if filter.name == "" {
+		return filter, fmt.Errorf("missing attribute name in [%s]", text)
+	}
+	return filter, nil
+}
@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSelectorDoc() *Document {
+	doc := NewDocument()
+
+	week := NewHeading(1, "Week 42")
+	doc.AddChild(week)
+	habits := NewHeading(2, "Habits")
+	week.AddChild(habits)
+
+	list := NewList(false)
+	exercise := NewTask(true, "Exercise")
+	exercise.AddChild(NewTask(false, "Stretch"))
+	list.AddChild(exercise)
+	read := NewTaskWithStatus(StatusCancelled, "Read #books")
+	read.SetInlines(NewText("Read "), NewTag("books"))
+	list.AddChild(read)
+	list.AddChild(NewTask(false, "Journal"))
+	habits.AddChild(list)
+	habits.AddChild(NewParagraph("Habits notes"))
+
+	notes := NewHeading(1, "Notes")
+	doc.AddChild(notes)
+	otherHabits := NewHeading(2, "Habits")
+	notes.AddChild(otherHabits)
+	otherHabits.AddChild(NewTask(false, "Not a habit"))
+
+	return doc
+}
+
+func TestSelect(t *testing.T) {
+	doc := buildSelectorDoc()
+
+	tests := []struct {
+		name     string
+		selector string
+		expected []string
+	}{
+		{"Scoped heading path", "# Week 42 > ## Habits > task", []string{"Exercise", "Read #books", "Journal"}},
+		{"Attribute filter", "# Week 42 > ## Habits > task[checked=false]", []string{"Read #books", "Journal"}},
+		{"Unscoped heading", "## Habits > task", []string{"Exercise", "Read #books", "Journal", "Not a habit"}},
+		{"Case-insensitive title", "# notes > ## HABITS > task", []string{"Not a habit"}},
+		{"Subtasks", "## Habits > task > task", []string{"Stretch"}},
+		{"Descendants", "# Week 42 >> task", []string{"Exercise", "Stretch", "Read #books", "Journal"}},
+		{"Status by name", "task[status=cancelled]", []string{"Read #books"}},
+		{"Status by character", "task[status=x]", []string{"Exercise"}},
+		{"Not equal", "## Habits > task[status!=todo][checked!=true]", []string{"Read #books"}},
+		{"Tag", "task[tag=books]", []string{"Read #books"}},
+		{"Contains", "task[content*=NAL]", []string{"Journal"}},
+		{"Field", "paragraph[content=\"Habits notes\"]", []string{"Habits notes"}},
+		{"Any type", "## Habits > *[type=paragraph]", []string{"Habits notes"}},
+		{"No match", "# Week 43 > task", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Select(doc, tt.selector)
+			assert.NoError(t, err)
+
+			var contents []string
+			for _, node := range nodes {
+				switch n := node.(type) {
+				case *Task:
+					contents = append(contents, n.Content)
+				case *Paragraph:
+					contents = append(contents, n.Content)
+				}
+			}
+			assert.Equal(t, tt.expected, contents)
+		})
+	}
+}
+
+func TestSelectWithTaskStatuses(t *testing.T) {
+	doc := NewDocument()
+	doc.AddChild(NewTaskWithStatus('?', "Call back"))
+	doc.AddChild(NewTaskWithStatus(StatusCancelled, "Read"))
+
+	statuses := DefaultTaskStatuses()
+	statuses['?'] = "question"
+	selector, err := CompileSelector("task[status=question]")
+	assert.NoError(t, err)
+
+	// Only the configured statuses have names
+	assert.Empty(t, selector.Select(doc))
+	nodes := selector.WithTaskStatuses(statuses).Select(doc)
+	assert.Len(t, nodes, 1)
+	assert.Equal(t, "Call back", nodes[0].(*Task).Content)
+}
+
+func TestSelectHeadings(t *testing.T) {
+	doc := buildSelectorDoc()
+
+	headings, err := Select(doc, "# * > ## Habits")
+	assert.NoError(t, err)
+	assert.Len(t, headings, 2)
+
+	headings, err = Select(doc, "heading[level=1]")
+	assert.NoError(t, err)
+	assert.Len(t, headings, 2)
+	assert.Equal(t, "Week 42", headings[0].(*Heading).Title)
+
+	// Selectors are relative to the node being searched
+	week := headings[0]
+	tasks, err := Select(week, "## Habits > task")
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 3)
+}
+
+func TestCompileSelectorErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"# Week 42 >",
+		"> task",
+		"####### Too deep",
+		"task[checked=false",
+		"task[=false]",
+		"task[title=\"open]",
+		"[checked=false]",
+		"tsak[checked=false]",
+		"## Habits > emphasis",
+	}
+
+	for _, selector := range tests {
+		t.Run(selector, func(t *testing.T) {
+			_, err := CompileSelector(selector)
+			assert.Error(t, err)
+		})
+	}
+}
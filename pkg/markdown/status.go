@@ -0,0 +1,51 @@
+package markdown
+
+// TaskStatus is the state of a task, written as the character between the
+// brackets of its checkbox
+type TaskStatus rune
+
+const (
+	StatusTodo       TaskStatus = ' '
+	StatusDone       TaskStatus = 'x'
+	StatusInProgress TaskStatus = '/'
+	StatusCancelled  TaskStatus = '-'
+	StatusMigrated   TaskStatus = '>'
+	StatusScheduled  TaskStatus = '<'
+)
+
+// Done reports whether the status marks the task as completed
+func (s TaskStatus) Done() bool {
+	return s == StatusDone || s == 'X'
+}
+
+// String returns the status character
+func (s TaskStatus) String() string {
+	if s == 0 {
+		return string(StatusTodo)
+	}
+	return string(rune(s))
+}
+
+// TaskStatusSet maps the status characters recognised in checkboxes to
+// their names
+type TaskStatusSet map[TaskStatus]string
+
+// DefaultTaskStatuses returns the standard checkbox states along with the
+// bullet journal states for in progress, cancelled, migrated and scheduled
+func DefaultTaskStatuses() TaskStatusSet {
+	return TaskStatusSet{
+		StatusTodo:       "todo",
+		StatusDone:       "done",
+		'X':              "done",
+		StatusInProgress: "in_progress",
+		StatusCancelled:  "cancelled",
+		StatusMigrated:   "migrated",
+		StatusScheduled:  "scheduled",
+	}
+}
+
+// Name returns the name of a status, or an empty string if the status is
+// not part of the set
+func (set TaskStatusSet) Name(status TaskStatus) string {
+	return set[status]
+}
@@ -0,0 +1,96 @@
+package markdown
+
+// Alignment is the text alignment of a table column
+type Alignment string
+
+const (
+	AlignNone   Alignment = ""
+	AlignLeft   Alignment = "left"
+	AlignCenter Alignment = "center"
+	AlignRight  Alignment = "right"
+)
+
+// Table represents a GFM table. Its children are rows, the first of which is
+// normally the header row.
+type Table struct {
+	BaseNode
+	Alignments []Alignment
+}
+
+func NewTable(alignments ...Alignment) *Table {
+	t := &Table{
+		BaseNode: BaseNode{
+			NodeType: NodeTable,
+			children: []Node{},
+		},
+		Alignments: alignments,
+	}
+	t.init(t)
+	return t
+}
+
+// Rows returns the table's rows
+func (t *Table) Rows() []*TableRow {
+	var rows []*TableRow
+	for _, child := range t.children {
+		if row, ok := child.(*TableRow); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// TableRow represents a row of table cells
+type TableRow struct {
+	BaseNode
+	Header bool
+}
+
+func NewTableRow(header bool, cells ...string) *TableRow {
+	r := &TableRow{
+		BaseNode: BaseNode{
+			NodeType: NodeTableRow,
+			children: []Node{},
+		},
+		Header: header,
+	}
+	r.init(r)
+	for _, cell := range cells {
+		r.AddChild(NewTableCell(cell))
+	}
+	return r
+}
+
+// Cells returns the row's cells
+func (r *TableRow) Cells() []*TableCell {
+	var cells []*TableCell
+	for _, child := range r.children {
+		if cell, ok := child.(*TableCell); ok {
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// TableCell represents a single table cell. Content holds the raw inline
+// markdown of the cell and Inlines the same text parsed into inline nodes.
+type TableCell struct {
+	BaseNode
+	Content string
+	Inlines []Node
+}
+
+func NewTableCell(content string) *TableCell {
+	c := &TableCell{
+		BaseNode: BaseNode{
+			NodeType: NodeTableCell,
+			children: []Node{},
+		},
+		Content: content,
+	}
+	c.init(c)
+	return c
+}
+
+func (c *TableCell) InlineNodes() []Node      { return c.Inlines }
+func (c *TableCell) SetInlines(nodes ...Node) { c.Inlines = setInlines(c, nodes) }
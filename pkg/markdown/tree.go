@@ -0,0 +1,126 @@
+package markdown
+
+// ChildIndex returns the position of child among the node's children, or -1
+// if it is not one of them
+func (n *BaseNode) ChildIndex(child Node) int {
+	for i, c := range n.children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+// InsertChild inserts child at index, detaching it from its current parent
+// first, and reports whether it did. The index refers to the children once
+// child has been detached; an index outside the children appends. A node
+// cannot be inserted under itself or one of its descendants.
+func (n *BaseNode) InsertChild(index int, child Node) bool {
+	if encloses(child, n.node()) {
+		return false
+	}
+	detach(child)
+	if index < 0 || index > len(n.children) {
+		index = len(n.children)
+	}
+	n.children = append(n.children, nil)
+	copy(n.children[index+1:], n.children[index:])
+	n.children[index] = child
+	child.SetParent(n.node())
+	n.modified = true
+	return true
+}
+
+// RemoveChild removes child from the node, reporting whether it was found
+func (n *BaseNode) RemoveChild(child Node) bool {
+	i := n.ChildIndex(child)
+	if i < 0 {
+		return false
+	}
+	n.RemoveChildAt(i)
+	return true
+}
+
+// RemoveChildAt removes and returns the child at index, or returns nil if
+// the index is out of range
+func (n *BaseNode) RemoveChildAt(index int) Node {
+	if index < 0 || index >= len(n.children) {
+		return nil
+	}
+	child := n.children[index]
+	n.children = append(n.children[:index], n.children[index+1:]...)
+	child.SetParent(nil)
+	n.modified = true
+	return child
+}
+
+// InsertBefore inserts child immediately before ref, reporting whether ref
+// is one of the node's children
+func (n *BaseNode) InsertBefore(child, ref Node) bool {
+	if child == ref || n.ChildIndex(ref) < 0 || encloses(child, n.node()) {
+		return false
+	}
+	detach(child)
+	return n.InsertChild(n.ChildIndex(ref), child)
+}
+
+// InsertAfter inserts child immediately after ref, reporting whether ref is
+// one of the node's children
+func (n *BaseNode) InsertAfter(child, ref Node) bool {
+	if child == ref || n.ChildIndex(ref) < 0 || encloses(child, n.node()) {
+		return false
+	}
+	detach(child)
+	return n.InsertChild(n.ChildIndex(ref)+1, child)
+}
+
+// ReplaceWith puts replacement in the node's place within its parent and
+// detaches the node, reporting whether it could: the node must have a
+// parent, and replacement must not be the node or one of its ancestors
+func (n *BaseNode) ReplaceWith(replacement Node) bool {
+	self := n.node()
+	parent := n.parent
+	if parent == nil || encloses(replacement, self) {
+		return false
+	}
+	detach(replacement)
+	index := parent.ChildIndex(self)
+	if index < 0 {
+		return false
+	}
+	parent.RemoveChildAt(index)
+	parent.InsertChild(index, replacement)
+	return true
+}
+
+// MoveTo detaches the node and inserts it into parent at index, reporting
+// whether it did. As with InsertChild, an index outside the new parent's
+// children appends, and a node cannot be moved under itself or one of its
+// descendants.
+func (n *BaseNode) MoveTo(parent Node, index int) bool {
+	return parent.InsertChild(index, n.node())
+}
+
+// encloses reports whether node is target or one of its ancestors, in which
+// case putting node under target would create a cycle
+func encloses(node, target Node) bool {
+	for ; target != nil; target = target.Parent() {
+		if target == node {
+			return true
+		}
+	}
+	return false
+}
+
+// detach removes a node from its parent, if it has one
+func detach(node Node) {
+	parent := node.Parent()
+	if parent == nil {
+		return
+	}
+	if !parent.RemoveChild(node) {
+		// Inline nodes point at their container without being one of its
+		// children
+		node.SetParent(nil)
+	}
+}
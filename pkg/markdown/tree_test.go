@@ -0,0 +1,175 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeMutation(t *testing.T) {
+	names := func(node Node) []string {
+		var result []string
+		for _, child := range node.Children() {
+			result = append(result, child.(*Task).Content)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(list *List, a, b, c *Task) bool
+		expected []string
+	}{
+		{
+			name:     "Remove child",
+			mutate:   func(list *List, a, b, c *Task) bool { return list.RemoveChild(b) },
+			expected: []string{"a", "c"},
+		},
+		{
+			name:     "Remove missing child",
+			mutate:   func(list *List, a, b, c *Task) bool { return !list.RemoveChild(NewTask(false, "x")) },
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "Remove child at index",
+			mutate:   func(list *List, a, b, c *Task) bool { return list.RemoveChildAt(0) == a },
+			expected: []string{"b", "c"},
+		},
+		{
+			name: "Insert child at index",
+			mutate: func(list *List, a, b, c *Task) bool {
+				list.InsertChild(1, NewTask(false, "x"))
+				return true
+			},
+			expected: []string{"a", "x", "b", "c"},
+		},
+		{
+			name: "Insert child out of range appends",
+			mutate: func(list *List, a, b, c *Task) bool {
+				list.InsertChild(10, NewTask(false, "x"))
+				return true
+			},
+			expected: []string{"a", "b", "c", "x"},
+		},
+		{
+			name:     "Insert before moves an existing child",
+			mutate:   func(list *List, a, b, c *Task) bool { return list.InsertBefore(c, a) },
+			expected: []string{"c", "a", "b"},
+		},
+		{
+			name:     "Insert after moves an existing child",
+			mutate:   func(list *List, a, b, c *Task) bool { return list.InsertAfter(a, c) },
+			expected: []string{"b", "c", "a"},
+		},
+		{
+			name: "Insert relative to a missing reference",
+			mutate: func(list *List, a, b, c *Task) bool {
+				return !list.InsertAfter(NewTask(false, "x"), NewTask(false, "y"))
+			},
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "Replace with new node",
+			mutate:   func(list *List, a, b, c *Task) bool { return b.ReplaceWith(NewTask(false, "x")) },
+			expected: []string{"a", "x", "c"},
+		},
+		{
+			name:     "Replace with sibling",
+			mutate:   func(list *List, a, b, c *Task) bool { return c.ReplaceWith(a) },
+			expected: []string{"b", "a"},
+		},
+		{
+			name: "Move within parent",
+			mutate: func(list *List, a, b, c *Task) bool {
+				a.MoveTo(list, 1)
+				return true
+			},
+			expected: []string{"b", "a", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := NewList(false)
+			a, b, c := NewTask(false, "a"), NewTask(false, "b"), NewTask(false, "c")
+			list.AddChild(a)
+			list.AddChild(b)
+			list.AddChild(c)
+
+			assert.True(t, tt.mutate(list, a, b, c))
+			assert.Equal(t, tt.expected, names(list))
+			for _, child := range list.Children() {
+				assert.Equal(t, list, child.Parent())
+			}
+		})
+	}
+}
+
+func TestTreeMutationParents(t *testing.T) {
+	doc := NewDocument()
+	first := NewHeading(1, "First")
+	second := NewHeading(1, "Second")
+	doc.AddChild(first)
+	doc.AddChild(second)
+
+	task := NewTask(false, "Gym")
+	first.AddChild(task)
+
+	// Moving a node detaches it from its old parent
+	task.MoveTo(second, 0)
+	assert.Empty(t, first.Children())
+	assert.Equal(t, []Node{task}, second.Children())
+	assert.Equal(t, second, task.Parent())
+
+	second.AddChild(NewParagraph("Note"))
+	first.AddChild(task)
+	assert.Len(t, second.Children(), 1)
+	assert.Equal(t, first, task.Parent())
+
+	removed := NewTask(false, "Read")
+	first.AddChild(removed)
+	assert.Equal(t, 1, first.ChildIndex(removed))
+	assert.True(t, first.RemoveChild(removed))
+	assert.Nil(t, removed.Parent())
+	assert.Equal(t, -1, first.ChildIndex(removed))
+
+	// Nodes without a parent cannot be replaced
+	assert.False(t, removed.ReplaceWith(NewTask(false, "x")))
+}
+
+func TestTreeMutationCycles(t *testing.T) {
+	doc := NewDocument()
+	heading := NewHeading(1, "Week 42")
+	doc.AddChild(heading)
+	list := NewList(false)
+	heading.AddChild(list)
+	task := NewTask(false, "Gym")
+	list.AddChild(task)
+	subtask := NewTask(false, "Stretch")
+	task.AddChild(subtask)
+
+	// A node cannot be put under itself or one of its descendants
+	assert.False(t, task.InsertChild(0, heading))
+	assert.False(t, heading.MoveTo(subtask, 0))
+	assert.False(t, task.MoveTo(task, 0))
+	assert.False(t, task.InsertBefore(list, subtask))
+	assert.False(t, task.InsertAfter(heading, subtask))
+	assert.False(t, subtask.ReplaceWith(list))
+	subtask.AddChild(heading)
+
+	// The tree is left as it was
+	assert.Equal(t, []Node{heading}, doc.Children())
+	assert.Equal(t, Node(doc), heading.Parent())
+	assert.Equal(t, Node(task), subtask.Parent())
+	assert.Empty(t, subtask.Children())
+	var visited int
+	Walk(doc, func(Node, bool) WalkStatus {
+		visited++
+		return WalkContinue
+	})
+	assert.Equal(t, 10, visited)
+
+	// Moving a node elsewhere still works
+	assert.True(t, subtask.MoveTo(heading, 0))
+	assert.Equal(t, Node(heading), subtask.Parent())
+}
@@ -1,6 +1,11 @@
 package markdown
 
-import "strings"
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
 
 // NodeType represents the type of markdown node
 type NodeType string
@@ -13,8 +18,56 @@ const (
 	NodeList      NodeType = "list"
 	NodeListItem  NodeType = "list_item"
 	NodeText      NodeType = "text"
+
+	NodeFrontMatter   NodeType = "front_matter"
+	NodeCodeBlock     NodeType = "code_block"
+	NodeBlockquote    NodeType = "blockquote"
+	NodeCallout       NodeType = "callout"
+	NodeThematicBreak NodeType = "thematic_break"
+	NodeTable         NodeType = "table"
+	NodeTableRow      NodeType = "table_row"
+	NodeTableCell     NodeType = "table_cell"
+	NodeRawBlock      NodeType = "raw_block"
+
+	// Inline nodes
+	NodeEmphasis NodeType = "emphasis"
+	NodeStrong   NodeType = "strong"
+	NodeCodeSpan NodeType = "code_span"
+	NodeLink     NodeType = "link"
+	NodeImage    NodeType = "image"
+	NodeWikiLink NodeType = "wiki_link"
+	NodeTag      NodeType = "tag"
 )
 
+// Span is a half-open byte range [Start, End) into the source a node was
+// parsed from. Spans are line aligned: Start is the beginning of the node's
+// first line and End is the end of its last line, excluding the line ending.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Len returns the number of bytes covered by the span
+func (s Span) Len() int { return s.End - s.Start }
+
+// Position is a location in the source a node was parsed from. Lines and
+// columns start at 1, and columns count characters rather than bytes.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Range is the part of the source a node was parsed from. Start is the
+// node's first non-blank character and End is just past its last character.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
 // Node is the base interface for all markdown nodes
 type Node interface {
 	Type() NodeType
@@ -23,6 +76,44 @@ type Node interface {
 	ClearChildren()
 	Parent() Node
 	SetParent(Node)
+
+	// Tree mutation. Inserting a node that already has a parent moves it,
+	// so parent pointers always agree with the children they belong to, and
+	// a node is never inserted under itself or one of its descendants.
+	ChildIndex(child Node) int
+	InsertChild(index int, child Node) bool
+	RemoveChild(child Node) bool
+	RemoveChildAt(index int) Node
+	InsertBefore(child, ref Node) bool
+	InsertAfter(child, ref Node) bool
+	ReplaceWith(replacement Node) bool
+	MoveTo(parent Node, index int) bool
+
+	// Span returns the node's original source range, if it was parsed from source
+	Span() (Span, bool)
+	SetSpan(Span)
+
+	// OwnSpan returns the source range of a container's own lines, such as
+	// a heading's line or a list item's text, excluding its children
+	OwnSpan() (Span, bool)
+	SetOwnSpan(Span)
+
+	// Range returns the node's original line and column range, if it was
+	// parsed from source
+	Range() (Range, bool)
+	SetRange(Range)
+
+	// ClearSpan forgets where the node came from, so that writers render it
+	// rather than copying from a source it no longer belongs to, such as
+	// after moving it into another document
+	ClearSpan()
+
+	// Modified reports whether the node's fields or children have changed
+	// since it was parsed. Writers copy unmodified nodes verbatim.
+	Modified() bool
+	FieldModified(name string) bool
+	MarkModified()
+	ResetModified()
 }
 
 // BaseNode provides common functionality for all nodes
@@ -30,13 +121,49 @@ type BaseNode struct {
 	NodeType NodeType
 	children []Node
 	parent   Node
+
+	// self is the concrete node embedding this BaseNode, so that parent
+	// pointers and field snapshots refer to the outer type
+	self Node
+
+	span       Span
+	hasSpan    bool
+	ownSpan    Span
+	hasOwnSpan bool
+	rng        Range
+	hasRange   bool
+	snapshot   map[string]string
+	modified   bool
+}
+
+// init wires the BaseNode to the concrete node embedding it
+func (n *BaseNode) init(self Node) {
+	n.self = self
+	if n.children == nil {
+		n.children = []Node{}
+	}
+}
+
+// node returns the concrete node embedding this BaseNode
+func (n *BaseNode) node() Node {
+	if n.self != nil {
+		return n.self
+	}
+	return n
 }
 
 func (n *BaseNode) Type() NodeType   { return n.NodeType }
 func (n *BaseNode) Children() []Node { return n.children }
 func (n *BaseNode) AddChild(child Node) {
+	// A node added under itself or one of its descendants would form a
+	// cycle, so it is left where it is
+	if encloses(child, n.node()) {
+		return
+	}
+	detach(child)
 	n.children = append(n.children, child)
-	child.SetParent(n)
+	child.SetParent(n.node())
+	n.modified = true
 }
 func (n *BaseNode) ClearChildren() {
 	// Clear parent references from existing children
@@ -45,6 +172,91 @@ func (n *BaseNode) ClearChildren() {
 	}
 	// Clear the children slice
 	n.children = []Node{}
+	n.modified = true
+}
+
+func (n *BaseNode) Span() (Span, bool) { return n.span, n.hasSpan }
+func (n *BaseNode) SetSpan(span Span) {
+	n.span = span
+	n.hasSpan = true
+}
+
+func (n *BaseNode) OwnSpan() (Span, bool) { return n.ownSpan, n.hasOwnSpan }
+func (n *BaseNode) SetOwnSpan(span Span) {
+	n.ownSpan = span
+	n.hasOwnSpan = true
+}
+
+func (n *BaseNode) Range() (Range, bool) { return n.rng, n.hasRange }
+func (n *BaseNode) SetRange(r Range) {
+	n.rng = r
+	n.hasRange = true
+}
+
+func (n *BaseNode) ClearSpan() {
+	n.hasSpan = false
+	n.hasOwnSpan = false
+	n.hasRange = false
+}
+
+// Modified reports whether the node was marked modified or any of its
+// exported fields differ from the snapshot taken by ResetModified
+func (n *BaseNode) Modified() bool {
+	if n.modified || n.snapshot == nil {
+		return true
+	}
+	current := fieldSnapshot(n.node())
+	for name, value := range current {
+		if n.snapshot[name] != value {
+			return true
+		}
+	}
+	return false
+}
+func (n *BaseNode) MarkModified() { n.modified = true }
+
+// FieldModified reports whether the named exported field differs from the
+// snapshot taken by ResetModified
+func (n *BaseNode) FieldModified(name string) bool {
+	if n.snapshot == nil {
+		return true
+	}
+	return n.snapshot[name] != fieldSnapshot(n.node())[name]
+}
+
+// ResetModified records the node's current fields as its pristine state
+func (n *BaseNode) ResetModified() {
+	n.modified = false
+	n.snapshot = fieldSnapshot(n.node())
+}
+
+// fieldSnapshot renders each exported field of a node's concrete type,
+// excluding the embedded BaseNode, so direct field assignments can be detected
+func fieldSnapshot(node Node) map[string]string {
+	snapshot := map[string]string{}
+
+	v := reflect.ValueOf(node)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return snapshot
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		if v.Field(i).Kind() == reflect.Ptr {
+			// Compare pointers by identity rather than by their contents
+			snapshot[field.Name] = fmt.Sprintf("%p", v.Field(i).Interface())
+			continue
+		}
+		snapshot[field.Name] = fmt.Sprintf("%#v", v.Field(i).Interface())
+	}
+	return snapshot
 }
 
 // AddChildNode is a helper that adds a child and sets parent correctly
@@ -57,31 +269,104 @@ func (n *BaseNode) SetParent(parent Node) { n.parent = parent }
 // Document represents the root of a markdown document tree
 type Document struct {
 	BaseNode
+
+	// FrontMatter is the document's YAML front matter, or nil if it has none
+	FrontMatter *FrontMatter
+
+	// LineEnding is the line ending new and rewritten lines are written
+	// with, LF or CRLF. Empty means LF. Lines copied from the source keep
+	// the line ending they were written with.
+	LineEnding string
+
+	// BOM is set when the document starts with a UTF-8 byte order mark
+	BOM bool
+
+	// Trailing is the blank space after the document's last block exactly
+	// as written, such as its final newline
+	Trailing string
+
+	source []byte
+
+	// mixed holds the offsets in source of the line endings the file wrote
+	// differently from LineEnding, in ascending order
+	mixed []int
 }
 
+// Line endings a document can be written with
+const (
+	LF   = "\n"
+	CRLF = "\r\n"
+)
+
 func NewDocument() *Document {
-	return &Document{
+	d := &Document{
 		BaseNode: BaseNode{
 			NodeType: NodeDocument,
 			children: []Node{},
 		},
 	}
+	d.init(d)
+	return d
 }
 
-func (d *Document) AddChild(child Node) {
-	d.children = append(d.children, child)
-	child.SetParent(d)
+// Source returns the markdown the document was parsed from, if any
+func (d *Document) Source() []byte { return d.source }
+
+// SetSource records the markdown the document was parsed from. Node spans
+// index into this source, which uses LF line endings and has no byte order
+// mark whatever the original file used.
+func (d *Document) SetSource(source []byte) { d.source = source }
+
+// SetMixedLineEndings records the offsets in the source of the line
+// endings the file wrote differently from LineEnding
+func (d *Document) SetMixedLineEndings(offsets []int) {
+	d.mixed = append([]int(nil), offsets...)
+	sort.Ints(d.mixed)
 }
 
-// Heading represents an ATX heading node
+// LineEndingAt returns the line ending the file used for the newline at
+// offset in the source
+func (d *Document) LineEndingAt(offset int) string {
+	ending := d.LineEnding
+	if ending == "" {
+		ending = LF
+	}
+	if i := sort.SearchInts(d.mixed, offset); i < len(d.mixed) && d.mixed[i] == offset {
+		if ending == LF {
+			return CRLF
+		}
+		return LF
+	}
+	return ending
+}
+
+// Heading represents a heading node. Title holds the raw inline markdown of
+// the heading and Inlines the same text parsed into inline nodes.
 type Heading struct {
 	BaseNode
-	Level int // 1-6
-	Title string
+	Level   int // 1-6
+	Title   string
+	Inlines []Node
+
+	// Setext is set for a heading underlined with "=" or "-" rather than
+	// prefixed with "#". Only levels 1 and 2 can be written this way.
+	Setext bool
+
+	// ID is the heading's explicit ID, written as "{#id}" after the title
+	ID string
+
+	// Attributes holds the rest of the heading's "{...}" attribute list,
+	// such as classes, as written
+	Attributes string
+
+	// AutoID is the ID generated from the title when the document was
+	// parsed, for headings without an explicit ID. It is unique within the
+	// document and is not written back.
+	AutoID string
 }
 
 func NewHeading(level int, title string) *Heading {
-	return &Heading{
+	h := &Heading{
 		BaseNode: BaseNode{
 			NodeType: NodeHeading,
 			children: []Node{},
@@ -89,77 +374,177 @@ func NewHeading(level int, title string) *Heading {
 		Level: level,
 		Title: title,
 	}
+	h.init(h)
+	return h
 }
 
-func (h *Heading) AddChild(child Node) {
-	h.children = append(h.children, child)
-	child.SetParent(h)
-}
-
-// Task represents a task/checkbox item
+// Task represents a task/checkbox item. Content holds the raw inline
+// markdown of the paragraph following the checkbox and Inlines the same
+// text parsed into inline nodes. The blocks that follow it in the item,
+// such as notes, code blocks and subtasks, are children.
 type Task struct {
 	BaseNode
-	Checked bool
+	Status  TaskStatus
 	Content string
+	Inlines []Node
+
+	// Metadata is parsed from Content; use SetMetadata to change both
+	Metadata TaskMetadata
+
+	// BlockID is the task's block reference ID, written as a trailing
+	// "^id" after the content
+	BlockID string
 }
 
+// NewTask creates a task that is either done or still to do
 func NewTask(checked bool, content string) *Task {
-	return &Task{
+	status := StatusTodo
+	if checked {
+		status = StatusDone
+	}
+	return NewTaskWithStatus(status, content)
+}
+
+// NewTaskWithStatus creates a task with any checkbox state
+func NewTaskWithStatus(status TaskStatus, content string) *Task {
+	t := &Task{
 		BaseNode: BaseNode{
 			NodeType: NodeTask,
 			children: []Node{},
 		},
-		Checked: checked,
-		Content: content,
+		Status:   status,
+		Content:  content,
+		Metadata: ParseTaskMetadata(content),
+	}
+	t.init(t)
+	return t
+}
+
+// Description returns the task's text without its metadata
+func (t *Task) Description() string {
+	return Description(t.Content)
+}
+
+// SetMetadata updates the task's metadata and rewrites the metadata in its
+// text to match, leaving the rest of the text as written
+func (t *Task) SetMetadata(meta TaskMetadata) {
+	t.Content = FormatTaskMetadata(t.Content, meta)
+	t.Metadata = ParseTaskMetadata(t.Content)
+}
+
+// Checked reports whether the task is done
+func (t *Task) Checked() bool {
+	return t.Status.Done()
+}
+
+// SetChecked marks the task as done or to do. A task that is already done
+// keeps its original checkbox character.
+func (t *Task) SetChecked(checked bool) {
+	if checked && t.Checked() {
+		return
+	}
+	if checked {
+		t.Status = StatusDone
+	} else {
+		t.Status = StatusTodo
+	}
+}
+
+// Subtasks returns the tasks nested directly under the task, whether added
+// as children or held in a nested list
+func (t *Task) Subtasks() []*Task {
+	var subtasks []*Task
+	for _, child := range t.children {
+		switch n := child.(type) {
+		case *Task:
+			subtasks = append(subtasks, n)
+		case *List:
+			for _, item := range n.children {
+				if task, ok := item.(*Task); ok {
+					subtasks = append(subtasks, task)
+				}
+			}
+		}
 	}
+	return subtasks
 }
 
-// Paragraph represents a paragraph of text
+// Paragraph represents a paragraph of text. Content holds the raw inline
+// markdown, including any emphasis or links, and Inlines the same text
+// parsed into inline nodes.
 type Paragraph struct {
 	BaseNode
 	Content string
+	Inlines []Node
+
+	// BlockID is the paragraph's block reference ID, written as a
+	// trailing "^id" after the content
+	BlockID string
 }
 
 func NewParagraph(content string) *Paragraph {
-	return &Paragraph{
+	p := &Paragraph{
 		BaseNode: BaseNode{
 			NodeType: NodeParagraph,
 			children: []Node{},
 		},
 		Content: content,
 	}
+	p.init(p)
+	return p
 }
 
-// List represents an unordered or ordered list
+// List represents an unordered or ordered list. Start is the number of the
+// first item of an ordered list. Marker is the bullet of an unordered list
+// ("-", "*" or "+") or the delimiter after the numbers of an ordered one
+// ("." or ")"); empty leaves the choice to the writer.
 type List struct {
 	BaseNode
 	Ordered bool
+	Start   int
+	Marker  string
 }
 
 func NewList(ordered bool) *List {
-	return &List{
+	l := &List{
 		BaseNode: BaseNode{
 			NodeType: NodeList,
 			children: []Node{},
 		},
 		Ordered: ordered,
 	}
+	if ordered {
+		l.Start = 1
+	}
+	l.init(l)
+	return l
 }
 
-// ListItem represents an item in a list
+// ListItem represents an item in a list. Content holds the raw inline
+// markdown of the item's first paragraph, which may span several lines, and
+// Inlines the same text parsed into inline nodes. The blocks that follow it
+// in the item, such as further paragraphs, code blocks and nested lists, are
+// children. An item that starts with another kind of block has no Content.
 type ListItem struct {
 	BaseNode
 	Content string
+	Inlines []Node
+
+	// BlockID is the item's block reference ID, written as a trailing
+	// "^id" after the content
+	BlockID string
 }
 
 func NewListItem(content string) *ListItem {
-	return &ListItem{
+	li := &ListItem{
 		BaseNode: BaseNode{
 			NodeType: NodeListItem,
 			children: []Node{},
 		},
 		Content: content,
 	}
+	li.init(li)
+	return li
 }
 
 // Text represents raw text content
@@ -169,13 +554,142 @@ type Text struct {
 }
 
 func NewText(content string) *Text {
-	return &Text{
+	t := &Text{
 		BaseNode: BaseNode{
 			NodeType: NodeText,
 			children: []Node{},
 		},
 		Content: content,
 	}
+	t.init(t)
+	return t
+}
+
+// CodeBlock represents a fenced or indented code block. Language is the
+// first word of the info string of a fenced block.
+type CodeBlock struct {
+	BaseNode
+	Language string
+	Info     string
+	Content  string
+	Fenced   bool
+	Fence    string // ``` or ~~~, possibly longer
+}
+
+func NewCodeBlock(language, content string) *CodeBlock {
+	c := &CodeBlock{
+		BaseNode: BaseNode{
+			NodeType: NodeCodeBlock,
+			children: []Node{},
+		},
+		Language: language,
+		Info:     language,
+		Content:  content,
+		Fenced:   true,
+		Fence:    "```",
+	}
+	c.init(c)
+	return c
+}
+
+// Blockquote represents a block quote; its children are the quoted blocks
+type Blockquote struct {
+	BaseNode
+}
+
+func NewBlockquote() *Blockquote {
+	b := &Blockquote{
+		BaseNode: BaseNode{
+			NodeType: NodeBlockquote,
+			children: []Node{},
+		},
+	}
+	b.init(b)
+	return b
+}
+
+// CalloutFold is whether a callout can be folded: "" for a callout that
+// cannot, "+" for one that starts expanded and "-" for one that starts
+// collapsed
+type CalloutFold string
+
+const (
+	FoldNone      CalloutFold = ""
+	FoldExpanded  CalloutFold = "+"
+	FoldCollapsed CalloutFold = "-"
+)
+
+// Callout represents an Obsidian style callout, a blockquote that starts
+// with "[!kind]" as in "> [!warning]- Title". Title holds the raw inline
+// markdown after the kind, empty for the default title, and Inlines the
+// same text parsed into inline nodes; the quoted blocks are children.
+type Callout struct {
+	BaseNode
+	Kind    string
+	Title   string
+	Fold    CalloutFold
+	Inlines []Node
+}
+
+func NewCallout(kind, title string) *Callout {
+	c := &Callout{
+		BaseNode: BaseNode{
+			NodeType: NodeCallout,
+			children: []Node{},
+		},
+		Kind:  kind,
+		Title: title,
+	}
+	c.init(c)
+	return c
+}
+
+// ThematicBreak represents a horizontal rule such as --- or * * *
+type ThematicBreak struct {
+	BaseNode
+	Marker string
+}
+
+func NewThematicBreak(marker string) *ThematicBreak {
+	t := &ThematicBreak{
+		BaseNode: BaseNode{
+			NodeType: NodeThematicBreak,
+			children: []Node{},
+		},
+		Marker: marker,
+	}
+	t.init(t)
+	return t
+}
+
+// RawBlock holds the source of a block the reader does not model, such as
+// an HTML block or a link reference definition, so that it is written back
+// unchanged. Kind names what the block is: one of the Raw* kinds, or the
+// parser's name for the block.
+type RawBlock struct {
+	BaseNode
+	Kind    string
+	Content string
+}
+
+// Kinds of raw block
+const (
+	RawHTML          = "html"
+	RawLinkReference = "link_reference"
+	RawFootnote      = "footnote"
+)
+
+func NewRawBlock(kind, content string) *RawBlock {
+	r := &RawBlock{
+		BaseNode: BaseNode{
+			NodeType: NodeRawBlock,
+			children: []Node{},
+		},
+		Kind:    kind,
+		Content: content,
+	}
+	r.init(r)
+	return r
 }
 
 // Utility functions
@@ -183,16 +697,82 @@ func NewText(content string) *Text {
 // FindHeadings recursively finds all heading nodes in the tree
 func FindHeadings(node Node) []*Heading {
 	var headings []*Heading
+	Walk(node, func(n Node, entering bool) WalkStatus {
+		if h, ok := n.(*Heading); ok && entering {
+			headings = append(headings, h)
+		}
+		return WalkContinue
+	})
+	return headings
+}
+
+// FindCallouts recursively finds all callouts in the tree
+func FindCallouts(node Node) []*Callout {
+	var callouts []*Callout
+	Walk(node, func(n Node, entering bool) WalkStatus {
+		if c, ok := n.(*Callout); ok && entering {
+			callouts = append(callouts, c)
+		}
+		return WalkContinue
+	})
+	return callouts
+}
 
-	if h, ok := node.(*Heading); ok {
-		headings = append(headings, h)
+// BlockID returns the block reference ID of a task, list item or
+// paragraph, or "" if the node has none
+func BlockID(node Node) string {
+	switch n := node.(type) {
+	case *Task:
+		return n.BlockID
+	case *ListItem:
+		return n.BlockID
+	case *Paragraph:
+		return n.BlockID
 	}
+	return ""
+}
 
-	for _, child := range node.Children() {
-		headings = append(headings, FindHeadings(child)...)
+// FindByBlockID finds the block with the given reference ID, with or
+// without its leading "^", so that a block can be found again after its
+// content changes
+func FindByBlockID(node Node, id string) Node {
+	id = strings.TrimPrefix(id, "^")
+	if id == "" {
+		return nil
 	}
+	var found Node
+	Walk(node, func(n Node, entering bool) WalkStatus {
+		if entering && BlockID(n) == id {
+			found = n
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	return found
+}
 
-	return headings
+// Anchor returns the ID links to the heading use: its explicit ID if it
+// has one, otherwise the one generated from its title
+func (h *Heading) Anchor() string {
+	if h.ID != "" {
+		return h.ID
+	}
+	return h.AutoID
+}
+
+// FindHeadingByID finds a heading by its explicit or generated ID, with or
+// without a leading "#"
+func FindHeadingByID(node Node, id string) *Heading {
+	id = strings.TrimPrefix(id, "#")
+	if id == "" {
+		return nil
+	}
+	for _, h := range FindHeadings(node) {
+		if h.Anchor() == id {
+			return h
+		}
+	}
+	return nil
 }
 
 // FindHeadingByTitle finds a heading by title (case-insensitive)
@@ -209,18 +789,30 @@ func FindHeadingByTitle(node Node, title string) *Heading {
 	return nil
 }
 
-// FindTasks recursively finds all task nodes in the tree
+// FindTasks recursively finds the task nodes in the tree. Subtasks are not
+// included; use Task.Subtasks or FindAllTasks to reach them.
 func FindTasks(node Node) []*Task {
 	var tasks []*Task
+	Walk(node, func(n Node, entering bool) WalkStatus {
+		if t, ok := n.(*Task); ok && entering {
+			tasks = append(tasks, t)
+			return WalkSkipChildren
+		}
+		return WalkContinue
+	})
+	return tasks
+}
 
-	if t, ok := node.(*Task); ok {
-		tasks = append(tasks, t)
-	}
-
-	for _, child := range node.Children() {
-		tasks = append(tasks, FindTasks(child)...)
-	}
-
+// FindAllTasks returns all tasks under a node including nested subtasks, in
+// document order
+func FindAllTasks(node Node) []*Task {
+	var tasks []*Task
+	Walk(node, func(n Node, entering bool) WalkStatus {
+		if t, ok := n.(*Task); ok && entering {
+			tasks = append(tasks, t)
+		}
+		return WalkContinue
+	})
 	return tasks
 }
 
@@ -47,6 +47,71 @@ func TestNodeTypes(t *testing.T) {
 			create:   func() Node { return NewText("Test text") },
 			expected: NodeText,
 		},
+		{
+			name:     "CodeBlock node",
+			create:   func() Node { return NewCodeBlock("go", "fmt.Println()") },
+			expected: NodeCodeBlock,
+		},
+		{
+			name:     "Blockquote node",
+			create:   func() Node { return NewBlockquote() },
+			expected: NodeBlockquote,
+		},
+		{
+			name:     "ThematicBreak node",
+			create:   func() Node { return NewThematicBreak("---") },
+			expected: NodeThematicBreak,
+		},
+		{
+			name:     "Emphasis node",
+			create:   func() Node { return NewEmphasis("*") },
+			expected: NodeEmphasis,
+		},
+		{
+			name:     "Strong node",
+			create:   func() Node { return NewStrong("**") },
+			expected: NodeStrong,
+		},
+		{
+			name:     "CodeSpan node",
+			create:   func() Node { return NewCodeSpan("code") },
+			expected: NodeCodeSpan,
+		},
+		{
+			name:     "Link node",
+			create:   func() Node { return NewLink("https://example.com", "") },
+			expected: NodeLink,
+		},
+		{
+			name:     "Image node",
+			create:   func() Node { return NewImage("image.png", "") },
+			expected: NodeImage,
+		},
+		{
+			name:     "WikiLink node",
+			create:   func() Node { return NewWikiLink("Atomic Habits", "") },
+			expected: NodeWikiLink,
+		},
+		{
+			name:     "Tag node",
+			create:   func() Node { return NewTag("project/planner") },
+			expected: NodeTag,
+		},
+		{
+			name:     "Table node",
+			create:   func() Node { return NewTable(AlignLeft, AlignRight) },
+			expected: NodeTable,
+		},
+		{
+			name:     "TableRow node",
+			create:   func() Node { return NewTableRow(true) },
+			expected: NodeTableRow,
+		},
+		{
+			name:     "TableCell node",
+			create:   func() Node { return NewTableCell("cell") },
+			expected: NodeTableCell,
+		},
 	}
 
 	for _, tt := range tests {
@@ -188,6 +253,21 @@ func TestFindHeadingByTitle(t *testing.T) {
 	}
 }
 
+func TestFindHeadingByID(t *testing.T) {
+	doc := NewDocument()
+	habits := NewHeading(2, "Habits")
+	habits.ID = "habits"
+	notes := NewHeading(2, "Notes")
+	notes.AutoID = "notes"
+	doc.AddChild(habits)
+	doc.AddChild(notes)
+
+	assert.Same(t, habits, FindHeadingByID(doc, "habits"))
+	assert.Same(t, notes, FindHeadingByID(doc, "#notes"))
+	assert.Nil(t, FindHeadingByID(doc, "missing"))
+	assert.Nil(t, FindHeadingByID(doc, ""))
+}
+
 func TestFindTasks(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -241,12 +321,95 @@ func TestFindTasks(t *testing.T) {
 
 			assert.Len(t, tasks, tt.expected)
 			for i, checked := range tt.checked {
-				assert.Equal(t, checked, tasks[i].Checked)
+				assert.Equal(t, checked, tasks[i].Checked())
 			}
 		})
 	}
 }
 
+func TestFindByBlockID(t *testing.T) {
+	doc := NewDocument()
+	heading := NewHeading(2, "Habits")
+	gym := NewTask(false, "Gym")
+	gym.BlockID = "gym"
+	note := NewParagraph("Rest day")
+	note.BlockID = "rest"
+	heading.AddChild(gym)
+	heading.AddChild(note)
+	doc.AddChild(heading)
+
+	assert.Same(t, gym, FindByBlockID(doc, "gym"))
+	assert.Same(t, note, FindByBlockID(doc, "^rest"))
+	assert.Nil(t, FindByBlockID(doc, "missing"))
+	assert.Nil(t, FindByBlockID(doc, ""))
+}
+
+func TestSubtasks(t *testing.T) {
+	doc := NewDocument()
+	parent := NewTask(false, "Exercise")
+	doc.AddChild(parent)
+
+	stretch := NewTask(true, "Stretch")
+	parent.AddChild(stretch)
+
+	nested := NewList(false)
+	run := NewTask(false, "Run")
+	nested.AddChild(run)
+	nested.AddChild(NewListItem("Note"))
+	parent.AddChild(nested)
+
+	grandchild := NewTask(false, "5km")
+	run.AddChild(grandchild)
+
+	doc.AddChild(NewTask(false, "Read"))
+
+	assert.Equal(t, []*Task{stretch, run}, parent.Subtasks())
+	assert.Equal(t, []*Task{grandchild}, run.Subtasks())
+
+	tasks := FindTasks(doc)
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, "Exercise", tasks[0].Content)
+	assert.Equal(t, "Read", tasks[1].Content)
+
+	var all []string
+	for _, task := range FindAllTasks(doc) {
+		all = append(all, task.Content)
+	}
+	assert.Equal(t, []string{"Exercise", "Stretch", "Run", "5km", "Read"}, all)
+}
+
+func TestTaskStatus(t *testing.T) {
+	tests := []struct {
+		status TaskStatus
+		name   string
+		done   bool
+	}{
+		{StatusTodo, "todo", false},
+		{StatusDone, "done", true},
+		{'X', "done", true},
+		{StatusInProgress, "in_progress", false},
+		{StatusCancelled, "cancelled", false},
+		{StatusMigrated, "migrated", false},
+		{StatusScheduled, "scheduled", false},
+		{'?', "", false},
+	}
+
+	statuses := DefaultTaskStatuses()
+	for _, tt := range tests {
+		t.Run(tt.status.String(), func(t *testing.T) {
+			assert.Equal(t, tt.name, statuses.Name(tt.status))
+			assert.Equal(t, tt.done, tt.status.Done())
+		})
+	}
+
+	task := NewTaskWithStatus('X', "Gym")
+	task.SetChecked(true)
+	assert.Equal(t, TaskStatus('X'), task.Status)
+	task.SetChecked(false)
+	assert.Equal(t, StatusTodo, task.Status)
+	assert.False(t, task.Checked())
+}
+
 func TestGetNodeDepth(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -331,7 +494,7 @@ func TestTaskNode(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			task := NewTask(tt.checked, tt.content)
-			assert.Equal(t, tt.checked, task.Checked)
+			assert.Equal(t, tt.checked, task.Checked())
 			assert.Equal(t, tt.content, task.Content)
 			assert.Equal(t, NodeTask, task.Type())
 		})
@@ -421,3 +584,34 @@ func TestComplexDocumentStructure(t *testing.T) {
 	assert.Nil(t, habit2.Parent())
 	assert.Nil(t, habit3.Parent())
 }
+
+func TestModifiedTracking(t *testing.T) {
+	task := NewTask(false, "Exercise")
+	assert.True(t, task.Modified(), "nodes that were never parsed count as modified")
+
+	task.SetSpan(Span{Start: 0, End: 14})
+	task.ResetModified()
+	assert.False(t, task.Modified())
+
+	span, ok := task.Span()
+	assert.True(t, ok)
+	assert.Equal(t, 14, span.Len())
+
+	// Direct field assignment is detected against the snapshot
+	task.SetChecked(true)
+	assert.True(t, task.Modified())
+	task.SetChecked(false)
+	assert.False(t, task.Modified())
+
+	// Structural changes mark the parent as modified
+	heading := NewHeading(2, "Habits")
+	heading.ResetModified()
+	heading.AddChild(task)
+	assert.True(t, heading.Modified())
+	assert.Equal(t, heading, task.Parent())
+
+	heading.ResetModified()
+	task.MarkModified()
+	assert.True(t, task.Modified())
+	assert.False(t, heading.Modified())
+}
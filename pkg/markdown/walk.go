@@ -0,0 +1,99 @@
+package markdown
+
+// WalkStatus tells Walk how to continue after visiting a node
+type WalkStatus int
+
+const (
+	// WalkContinue continues into the node's inline nodes and children
+	WalkContinue WalkStatus = iota
+	// WalkSkipChildren skips the node's inline nodes and children. It only
+	// has an effect when entering a node.
+	WalkSkipChildren
+	// WalkStop ends the walk
+	WalkStop
+)
+
+// Walker is called for each node visited by Walk, once when entering the
+// node and again when leaving it
+type Walker func(n Node, entering bool) WalkStatus
+
+// Walk visits node and everything beneath it depth first in document order.
+// A block's inline nodes are visited before its block children. Walk visits
+// the children a node had when it was entered, so fn may edit nodes, but
+// adding or removing nodes is better done with Transform.
+func Walk(node Node, fn Walker) WalkStatus {
+	status := fn(node, true)
+	if status == WalkStop {
+		return WalkStop
+	}
+
+	if status != WalkSkipChildren {
+		if container, ok := node.(InlineContainer); ok {
+			for _, inline := range append([]Node(nil), container.InlineNodes()...) {
+				if Walk(inline, fn) == WalkStop {
+					return WalkStop
+				}
+			}
+		}
+		for _, child := range append([]Node(nil), node.Children()...) {
+			if Walk(child, fn) == WalkStop {
+				return WalkStop
+			}
+		}
+	}
+
+	if fn(node, false) == WalkStop {
+		return WalkStop
+	}
+	return WalkContinue
+}
+
+// Transform calls fn for every node beneath root in document order,
+// including inline nodes, and puts the node fn returns in its place:
+// returning the node keeps it, returning another node replaces it and
+// returning nil deletes it. Transform continues into the children of kept
+// nodes but not into replacements, so a replacement may safely contain the
+// node it replaces.
+func Transform(root Node, fn func(Node) Node) {
+	if container, ok := root.(InlineContainer); ok {
+		inlines := container.InlineNodes()
+		updated := make([]Node, 0, len(inlines))
+		changed := false
+		for _, inline := range inlines {
+			result := fn(inline)
+			if result == inline {
+				Transform(inline, fn)
+				updated = append(updated, inline)
+				continue
+			}
+			changed = true
+			inline.SetParent(nil)
+			if result != nil {
+				updated = append(updated, result)
+			}
+		}
+		if changed {
+			container.SetInlines(updated...)
+		}
+	}
+
+	for _, child := range append([]Node(nil), root.Children()...) {
+		index := root.ChildIndex(child)
+		if index < 0 {
+			// Removed while transforming an earlier sibling
+			continue
+		}
+
+		result := fn(child)
+		switch result {
+		case child:
+			Transform(child, fn)
+		case nil:
+			root.RemoveChild(child)
+		default:
+			// fn may already have moved the node into its replacement
+			root.RemoveChild(child)
+			root.InsertChild(index, result)
+		}
+	}
+}
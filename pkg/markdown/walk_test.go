@@ -0,0 +1,142 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildWalkDoc() *Document {
+	doc := NewDocument()
+	habits := NewHeading(2, "Habits")
+	doc.AddChild(habits)
+
+	list := NewList(false)
+	gym := NewTask(true, "Gym #health")
+	gym.SetInlines(NewText("Gym "), NewTag("health"))
+	gym.AddChild(NewTask(true, "Stretch"))
+	list.AddChild(gym)
+	list.AddChild(NewTask(false, "Read"))
+	habits.AddChild(list)
+	habits.AddChild(NewParagraph("Notes"))
+
+	return doc
+}
+
+func TestWalk(t *testing.T) {
+	doc := buildWalkDoc()
+
+	var events []string
+	Walk(doc, func(n Node, entering bool) WalkStatus {
+		prefix := "leave "
+		if entering {
+			prefix = "enter "
+		}
+		events = append(events, prefix+string(n.Type()))
+		return WalkContinue
+	})
+
+	assert.Equal(t, []string{
+		"enter document",
+		"enter heading",
+		"enter list",
+		"enter task",
+		"enter text", "leave text",
+		"enter tag", "leave tag",
+		"enter task", "leave task",
+		"leave task",
+		"enter task", "leave task",
+		"leave list",
+		"enter paragraph", "leave paragraph",
+		"leave heading",
+		"leave document",
+	}, events)
+}
+
+func TestWalkStatus(t *testing.T) {
+	doc := buildWalkDoc()
+
+	var visited []NodeType
+	Walk(doc, func(n Node, entering bool) WalkStatus {
+		if !entering {
+			return WalkContinue
+		}
+		visited = append(visited, n.Type())
+		if n.Type() == NodeTask {
+			return WalkSkipChildren
+		}
+		return WalkContinue
+	})
+	assert.Equal(t, []NodeType{NodeDocument, NodeHeading, NodeList, NodeTask, NodeTask, NodeParagraph}, visited)
+
+	var first *Task
+	status := Walk(doc, func(n Node, entering bool) WalkStatus {
+		if task, ok := n.(*Task); ok {
+			first = task
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	assert.Equal(t, WalkStop, status)
+	assert.Equal(t, "Gym #health", first.Content)
+}
+
+func TestTransform(t *testing.T) {
+	t.Run("Uncheck all tasks", func(t *testing.T) {
+		doc := buildWalkDoc()
+		Transform(doc, func(n Node) Node {
+			if task, ok := n.(*Task); ok {
+				task.SetChecked(false)
+			}
+			return n
+		})
+		for _, task := range FindAllTasks(doc) {
+			assert.False(t, task.Checked())
+		}
+	})
+
+	t.Run("Rename a tag", func(t *testing.T) {
+		doc := buildWalkDoc()
+		Transform(doc, func(n Node) Node {
+			if tag, ok := n.(*Tag); ok && tag.Name == "health" {
+				return NewTag("fitness")
+			}
+			return n
+		})
+		tags := FindTags(doc)
+		assert.Len(t, tags, 1)
+		assert.Equal(t, "fitness", tags[0].Name)
+		assert.Equal(t, FindTasks(doc)[0], tags[0].Parent())
+	})
+
+	t.Run("Delete nodes", func(t *testing.T) {
+		doc := buildWalkDoc()
+		Transform(doc, func(n Node) Node {
+			if task, ok := n.(*Task); ok && task.Checked() {
+				return nil
+			}
+			return n
+		})
+		tasks := FindAllTasks(doc)
+		assert.Len(t, tasks, 1)
+		assert.Equal(t, "Read", tasks[0].Content)
+	})
+
+	t.Run("Wrap a node", func(t *testing.T) {
+		doc := buildWalkDoc()
+		Transform(doc, func(n Node) Node {
+			if p, ok := n.(*Paragraph); ok {
+				quote := NewBlockquote()
+				quote.AddChild(p)
+				return quote
+			}
+			return n
+		})
+		habits := FindHeadingByTitle(doc, "Habits")
+		assert.Len(t, habits.Children(), 2)
+		quote, ok := habits.Children()[1].(*Blockquote)
+		assert.True(t, ok)
+		assert.Equal(t, habits, quote.Parent())
+		assert.Equal(t, "Notes", quote.Children()[0].(*Paragraph).Content)
+	})
+}
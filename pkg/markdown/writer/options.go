@@ -0,0 +1,145 @@
+package writer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/notedownorg/planner/pkg/markdown"
+)
+
+// Numbering is how the items of an ordered list are numbered
+type Numbering string
+
+const (
+	// NumberingSequential counts up from the list's start number: 1., 2.,
+	// 3. and so on
+	NumberingSequential Numbering = "sequential"
+	// NumberingLazy writes the list's start number for every item, so
+	// reordering items leaves the other lines unchanged
+	NumberingLazy Numbering = "lazy"
+)
+
+// Options controls the style of rendered markdown. Nodes copied verbatim
+// from their source, and the whitespace between them, keep the style they
+// were written in.
+type Options struct {
+	// BulletMarker is written before the items of new unordered lists: "-",
+	// "*" or "+". Lists parsed from source keep their own marker, so
+	// rewriting one item does not split its list.
+	BulletMarker string
+
+	// CheckedChar is written in the checkbox of done tasks, 'x' or 'X'.
+	// Zero, or any other character, keeps each task's own character.
+	CheckedChar rune
+
+	// IndentWidth is the number of spaces nested blocks are indented under
+	// a list item. Zero, or a width too narrow to nest under the item's
+	// marker, aligns them with the item's text.
+	IndentWidth int
+
+	// OrderedNumbering is how the items of new ordered lists are numbered.
+	// Lists parsed from source keep numbering the way they were written.
+	OrderedNumbering Numbering
+
+	// BlankLinesAroundHeadings is the number of blank lines written before
+	// and after a heading
+	BlankLinesAroundHeadings int
+
+	// TrailingNewline ends the document with a newline. Documents parsed
+	// from source that ended with one keep it either way.
+	TrailingNewline bool
+}
+
+// DefaultOptions returns the style used by WriteDocument
+func DefaultOptions() Options {
+	return Options{
+		BulletMarker:             "-",
+		OrderedNumbering:         NumberingSequential,
+		BlankLinesAroundHeadings: 1,
+	}
+}
+
+// bullet returns the configured marker for unordered items
+func (o Options) bullet() string {
+	switch o.BulletMarker {
+	case "*", "+":
+		return o.BulletMarker
+	}
+	return "-"
+}
+
+// listMarker returns the bullet or delimiter used by a list's items. A list
+// without one of its own gets the configured style, switching to another
+// marker when the list before it uses the same one; otherwise the two would
+// be read back as a single list.
+func (o Options) listMarker(list *markdown.List) string {
+	if list.Marker != "" {
+		return list.Marker
+	}
+	marker, other := o.bullet(), "*"
+	if list.Ordered {
+		marker, other = ".", ")"
+	}
+	if marker == other {
+		other = "-"
+	}
+	if parent := list.Parent(); parent != nil {
+		if i := parent.ChildIndex(list); i > 0 {
+			if prev, ok := parent.Children()[i-1].(*markdown.List); ok &&
+				prev.Ordered == list.Ordered && o.listMarker(prev) == marker {
+				return other
+			}
+		}
+	}
+	return marker
+}
+
+// itemMarker returns the marker for a list item or task, including the
+// space that follows it
+func (o Options) itemMarker(item markdown.Node) string {
+	list, ok := item.Parent().(*markdown.List)
+	if !ok {
+		return o.bullet() + " "
+	}
+	marker := o.listMarker(list)
+	if !list.Ordered {
+		return marker + " "
+	}
+	return strconv.Itoa(o.itemNumber(list, item)) + marker + " "
+}
+
+// itemNumber returns the number of an item in an ordered list
+func (o Options) itemNumber(list *markdown.List, item markdown.Node) int {
+	if o.OrderedNumbering == NumberingLazy {
+		return list.Start
+	}
+	return list.Start + list.ChildIndex(item)
+}
+
+// childIndent returns the indentation of blocks nested under an item with
+// the given marker
+func (o Options) childIndent(marker string) string {
+	width := len(marker)
+	if o.IndentWidth > width {
+		width = o.IndentWidth
+	}
+	return strings.Repeat(" ", width)
+}
+
+// checkbox returns the checkbox for a task status
+func (o Options) checkbox(status markdown.TaskStatus) string {
+	if status.Done() && (o.CheckedChar == 'x' || o.CheckedChar == 'X') {
+		status = markdown.TaskStatus(o.CheckedChar)
+	}
+	return "[" + status.String() + "] "
+}
+
+// headingSeparator returns the separator written between a heading and the
+// block before or after it
+func (o Options) headingSeparator() string {
+	blank := o.BlankLinesAroundHeadings
+	if blank < 0 {
+		blank = 0
+	}
+	return strings.Repeat("\n", blank+1)
+}
@@ -1,184 +1,961 @@
 package writer
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/notedownorg/planner/pkg/markdown"
 )
 
-// WriteDocument converts a Document tree back to markdown format
+// WriteDocument converts a Document tree back to markdown format. Nodes that
+// were parsed from source and have not been modified since are copied from
+// the original source verbatim; everything else is rendered canonically.
 func WriteDocument(doc *markdown.Document) string {
-	var builder strings.Builder
-	writeNode(&builder, doc, 0)
-	return strings.TrimSpace(builder.String())
+	return WriteDocumentWithOptions(doc, DefaultOptions())
+}
+
+// WriteDocumentWithOptions converts a Document tree back to markdown,
+// rendering modified and new nodes in the given style. Lines copied from the
+// source keep their line endings, new lines use the document's, and the
+// document's byte order mark and trailing blank space are written back.
+func WriteDocumentWithOptions(doc *markdown.Document, opts Options) string {
+	w := &writer{source: doc.Source(), opts: opts}
+	w.writeNode(doc, "")
+	// Only line endings are trimmed; spaces at either end come from the
+	// source, where they can be significant
+	output := w.lineEndings(strings.TrimRight(w.String(), "\n"), doc)
+	if doc.Trailing != "" {
+		output += doc.Trailing
+	} else if opts.TrailingNewline && output != "" {
+		output += newline(doc)
+	}
+	if doc.BOM {
+		output = "\uFEFF" + output
+	}
+	return output
+}
+
+// writer renders a node tree, reusing the original source where possible
+type writer struct {
+	strings.Builder
+	source []byte
+	opts   Options
+
+	// copies records the parts of the output copied from the source
+	copies []copied
+
+	// A writer for the content of a blockquote or list item reads its
+	// lines from outer's source with their prefixes removed
+	outer *writer
+	lines []nestedLine
 }
 
-// writeNode recursively writes a node and its children
-func writeNode(builder *strings.Builder, node markdown.Node, depth int) {
+// nestedLine is a line of a nested writer's source
+type nestedLine struct {
+	start  int // offset of the line in the outer writer's source
+	prefix int // length of the prefix removed from it
+	out    int // offset of the line in the nested writer's source
+}
+
+// copied is a span of the source copied into the output at offset out
+type copied struct {
+	out  int
+	span markdown.Span
+}
+
+// writeNode writes a node and its children. Every line the node writes,
+// including its first, starts with indent.
+func (w *writer) writeNode(node markdown.Node, indent string) {
+	if w.writeVerbatim(node) {
+		return
+	}
+
 	switch n := node.(type) {
 	case *markdown.Document:
-		// Write all children
-		for i, child := range n.Children() {
-			if i > 0 {
-				builder.WriteString("\n")
+		if n.FrontMatter != nil {
+			w.writeNode(n.FrontMatter, "")
+			if children := n.Children(); len(children) > 0 {
+				if gap, ok := w.gap(n.FrontMatter, children[0]); ok {
+					w.copySource(gap)
+				} else {
+					w.WriteString("\n\n")
+				}
+			}
+		} else if children := n.Children(); len(children) > 0 {
+			// Blank lines before the first block are kept
+			if first, ok := w.span(children[0]); ok {
+				if gap, ok := w.whitespace(0, first.Start); ok {
+					w.copySource(gap)
+				}
 			}
-			writeNode(builder, child, depth)
 		}
+		w.writeChildren(n, "")
+
+	case *markdown.FrontMatter:
+		// When a value cannot be encoded the front matter is copied as it
+		// was parsed, so the edit is lost rather than the value. Front
+		// matter with no source drops the value rather than writing
+		// invalid YAML.
+		yaml, err := n.YAML()
+		if span, ok := w.span(n); ok && err != nil {
+			w.copySource(span)
+			break
+		}
+		w.WriteString("---\n")
+		w.WriteString(yaml)
+		w.WriteString("---")
 
 	case *markdown.Heading:
-		// Write the heading
-		WriteHeading(builder, n.Level, n.Title)
-
-		// Write children with proper spacing
-		for i, child := range n.Children() {
-			// Add spacing logic based on previous and current child types
-			if i > 0 {
-				prev := n.Children()[i-1]
-
-				// Check if previous sibling ends with a task
-				prevEndsWithTask := false
-				if prevHeading, ok := prev.(*markdown.Heading); ok {
-					if len(prevHeading.Children()) > 0 {
-						lastChild := prevHeading.Children()[len(prevHeading.Children())-1]
-						if _, isTask := lastChild.(*markdown.Task); isTask {
-							prevEndsWithTask = true
-						}
-					}
-				} else if _, isTask := prev.(*markdown.Task); isTask {
-					prevEndsWithTask = true
-				}
+		if !w.writeOwnVerbatim(n) {
+			w.WriteString(headingText(n))
+		}
+		w.writeChildren(n, "")
 
-				// Determine spacing based on node types and previous ending
-				if _, isPrevTask := prev.(*markdown.Task); isPrevTask {
-					if _, isCurrentTask := child.(*markdown.Task); isCurrentTask {
-						// Consecutive tasks, don't add extra spacing (task already has \n)
-					} else if _, isCurrentHeading := child.(*markdown.Heading); isCurrentHeading {
-						// Task to heading, add one extra spacing
-						builder.WriteString("\n")
-					} else {
-						// Task to other node, no extra spacing (task already has \n)
-					}
-				} else {
-					// Previous was not a task, but check if it ended with a task
-					if _, isCurrentHeading := child.(*markdown.Heading); isCurrentHeading {
-						if prevEndsWithTask {
-							// Previous section ended with task, only add one newline
-							builder.WriteString("\n")
-						} else {
-							// Normal spacing between headings
-							builder.WriteString("\n\n")
-						}
-					} else {
-						builder.WriteString("\n")
-					}
-				}
+	case *markdown.Paragraph:
+		w.writeLines(withBlockID(inlineText(n, "Content", n.Content), n.BlockID), indent, indent)
+
+	case *markdown.Task:
+		marker := w.itemMarker(n)
+		content := withBlockID(inlineText(n, "Content", n.Content), n.BlockID)
+		if !w.writeOwnVerbatim(n) {
+			w.writeLines(content, indent+marker+w.opts.checkbox(n.Status), indent+strings.Repeat(" ", len(marker)))
+		}
+		w.writeChildren(n, indent+w.opts.childIndent(marker))
+
+	case *markdown.List:
+		w.writeChildren(n, indent)
+
+	case *markdown.ListItem:
+		marker := w.itemMarker(n)
+		childIndent := indent + w.opts.childIndent(marker)
+		if w.writeOwnVerbatim(n) {
+			w.writeChildren(n, childIndent)
+			break
+		}
+		content := withBlockID(inlineText(n, "Content", n.Content), n.BlockID)
+		if content == "" && len(n.Children()) > 0 {
+			w.writeItemBlocks(n, indent+marker, childIndent)
+			break
+		}
+		w.writeLines(content, indent+marker, indent+strings.Repeat(" ", len(marker)))
+		w.writeChildren(n, childIndent)
+
+	case *markdown.CodeBlock:
+		if !n.Fenced {
+			w.writeLines(n.Content, indent+"    ", indent+"    ")
+			break
+		}
+		fence := n.Fence
+		if fence == "" {
+			fence = "```"
+		}
+		// The fence must be longer than any run of fence characters inside
+		for strings.Contains(n.Content, fence) {
+			fence += fence[:1]
+		}
+		w.WriteString(indent + fence + n.Info + "\n")
+		if n.Content != "" {
+			w.writeLines(n.Content, indent, indent)
+			w.WriteString("\n")
+		}
+		w.WriteString(indent + fence)
+
+	case *markdown.Blockquote:
+		w.writeQuoted(n, indent)
+
+	case *markdown.Callout:
+		if !w.writeOwnVerbatim(n) {
+			w.WriteString(indent + "> " + calloutLine(n))
+		}
+		if len(n.Children()) > 0 {
+			// The title line keeps its line ending
+			if own, ok := w.ownSpan(n); ok && own.End < len(w.source) && w.source[own.End] == '\n' {
+				w.copySource(markdown.Span{Start: own.End, End: own.End + 1})
 			} else {
-				builder.WriteString("\n")
+				w.WriteString("\n")
 			}
-			writeNode(builder, child, depth+1)
+			w.writeQuoted(n, indent)
 		}
 
-	case *markdown.Paragraph:
-		builder.WriteString(n.Content)
+	case *markdown.Table:
+		w.writeTable(n, indent)
+
+	case *markdown.RawBlock:
+		w.writeLines(n.Content, indent, indent)
 
-		// Write children
-		for _, child := range n.Children() {
-			writeNode(builder, child, depth+1)
+	case *markdown.ThematicBreak:
+		marker := n.Marker
+		if marker == "" {
+			marker = "---"
 		}
+		w.WriteString(indent + marker)
 
-	case *markdown.Task:
-		// Write task without extra indentation
-		if n.Checked {
-			builder.WriteString("- [x] ")
+	case *markdown.Text:
+		w.WriteString(n.Content)
+
+		// Write children (though text nodes typically don't have children)
+		w.writeChildren(n, indent)
+	}
+}
+
+// writeTable writes a table with every column padded to a common width. The
+// first row is used as the header if no row is marked as one.
+func (w *writer) writeTable(table *markdown.Table, indent string) {
+	rows := table.Rows()
+	if len(rows) == 0 {
+		return
+	}
+	for i, row := range rows {
+		if row.Header && i > 0 {
+			rows[0], rows[i] = rows[i], rows[0]
+			break
+		}
+	}
+
+	columns := len(table.Alignments)
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		for _, cell := range row.Cells() {
+			cells[i] = append(cells[i], escapePipes(inlineText(cell, "Content", cell.Content)))
+		}
+		columns = max(columns, len(cells[i]))
+	}
+
+	// A delimiter needs at least three characters
+	widths := make([]int, columns)
+	for col := range widths {
+		widths[col] = 3
+		for _, row := range cells {
+			if col < len(row) {
+				widths[col] = max(widths[col], utf8.RuneCountInString(row[col]))
+			}
+		}
+	}
+
+	alignment := func(col int) markdown.Alignment {
+		if col < len(table.Alignments) {
+			return table.Alignments[col]
+		}
+		return markdown.AlignNone
+	}
+
+	writeRow := func(row []string) {
+		w.WriteString(indent + "|")
+		for col, width := range widths {
+			var text string
+			if col < len(row) {
+				text = row[col]
+			}
+			w.WriteString(" " + padCell(text, width, alignment(col)) + " |")
+		}
+	}
+
+	writeRow(cells[0])
+	w.WriteString("\n" + indent + "|")
+	for col, width := range widths {
+		w.WriteString(" " + delimiterCell(width, alignment(col)) + " |")
+	}
+	for _, row := range cells[1:] {
+		w.WriteString("\n")
+		writeRow(row)
+	}
+}
+
+// padCell pads text to width according to the column alignment
+func padCell(text string, width int, alignment markdown.Alignment) string {
+	padding := width - utf8.RuneCountInString(text)
+	switch alignment {
+	case markdown.AlignRight:
+		return strings.Repeat(" ", padding) + text
+	case markdown.AlignCenter:
+		left := padding / 2
+		return strings.Repeat(" ", left) + text + strings.Repeat(" ", padding-left)
+	default:
+		return text + strings.Repeat(" ", padding)
+	}
+}
+
+// delimiterCell returns the delimiter row cell for a column
+func delimiterCell(width int, alignment markdown.Alignment) string {
+	switch alignment {
+	case markdown.AlignLeft:
+		return ":" + strings.Repeat("-", width-1)
+	case markdown.AlignRight:
+		return strings.Repeat("-", width-1) + ":"
+	case markdown.AlignCenter:
+		return ":" + strings.Repeat("-", width-2) + ":"
+	default:
+		return strings.Repeat("-", width)
+	}
+}
+
+// escapePipes escapes any unescaped pipe characters so cell text cannot
+// split into extra columns
+func escapePipes(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '|' && (i == 0 || text[i-1] != '\\') {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// inlineText returns the text to write for a block. The raw text field and
+// the inline nodes normally agree; when they differ, whichever was edited
+// since parsing wins, with the text field taking precedence unless it is empty.
+func inlineText(node markdown.InlineContainer, field, text string) string {
+	inlines := node.InlineNodes()
+	if len(inlines) == 0 {
+		return text
+	}
+
+	inlinesChanged := node.FieldModified("Inlines") || anyModified(inlines)
+	if inlinesChanged && (text == "" || !node.FieldModified(field)) {
+		var b strings.Builder
+		writeInlines(&b, inlines)
+		return b.String()
+	}
+	return text
+}
+
+// withBlockID appends a block reference ID to a block's text
+func withBlockID(text, id string) string {
+	if id == "" {
+		return text
+	}
+	if text == "" {
+		return "^" + id
+	}
+	return text + " ^" + id
+}
+
+// selfModified reports whether a node's own fields or inline content changed
+func selfModified(node markdown.Node) bool {
+	if node.Modified() {
+		return true
+	}
+	if container, ok := node.(markdown.InlineContainer); ok {
+		return anyModified(container.InlineNodes())
+	}
+	return false
+}
+
+// anyModified reports whether any of the nodes or their descendants changed
+func anyModified(nodes []markdown.Node) bool {
+	for _, node := range nodes {
+		if node.Modified() || anyModified(node.Children()) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeInlines renders inline nodes as markdown
+func writeInlines(b *strings.Builder, nodes []markdown.Node) {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *markdown.Text:
+			b.WriteString(n.Content)
+
+		case *markdown.Emphasis:
+			delimiter := n.Delimiter
+			if delimiter == "" {
+				delimiter = "*"
+			}
+			b.WriteString(delimiter)
+			writeInlines(b, n.Children())
+			b.WriteString(delimiter)
+
+		case *markdown.Strong:
+			delimiter := n.Delimiter
+			if delimiter == "" {
+				delimiter = "**"
+			}
+			b.WriteString(delimiter)
+			writeInlines(b, n.Children())
+			b.WriteString(delimiter)
+
+		case *markdown.CodeSpan:
+			b.WriteString(n.Delimiter)
+			b.WriteString(n.Content)
+			b.WriteString(n.Delimiter)
+
+		case *markdown.Link:
+			if n.Autolink {
+				b.WriteString("<" + n.Destination + ">")
+				continue
+			}
+			b.WriteString("[")
+			writeInlines(b, n.Children())
+			b.WriteString("](")
+			writeDestination(b, n.Destination, n.Title)
+			b.WriteString(")")
+
+		case *markdown.Image:
+			b.WriteString("![")
+			writeInlines(b, n.Children())
+			b.WriteString("](")
+			writeDestination(b, n.Destination, n.Title)
+			b.WriteString(")")
+
+		case *markdown.WikiLink:
+			if n.Embed {
+				b.WriteString("!")
+			}
+			b.WriteString("[[")
+			b.WriteString(n.Destination())
+			if n.Alias != "" {
+				b.WriteString("|")
+				b.WriteString(n.Alias)
+			}
+			b.WriteString("]]")
+
+		case *markdown.Tag:
+			b.WriteString("#")
+			b.WriteString(n.Name)
+
+		default:
+			writeInlines(b, n.Children())
+		}
+	}
+}
+
+// writeDestination writes a link destination and optional title
+func writeDestination(b *strings.Builder, destination, title string) {
+	if strings.ContainsAny(destination, " ()") {
+		b.WriteString("<" + destination + ">")
+	} else {
+		b.WriteString(destination)
+	}
+	if title != "" {
+		b.WriteString(` "`)
+		b.WriteString(strings.ReplaceAll(title, `"`, `\"`))
+		b.WriteString(`"`)
+	}
+}
+
+// writeChildren writes the children of node separated by either the
+// original whitespace between them or canonical spacing
+func (w *writer) writeChildren(node markdown.Node, indent string) {
+	children := node.Children()
+	for i, child := range children {
+		if i == 0 {
+			switch node.(type) {
+			case *markdown.Document, *markdown.List, *markdown.Blockquote, *markdown.Callout:
+				// Nothing precedes the first child
+			default:
+				if gap, ok := w.leadingGap(node, child); ok {
+					w.copySource(gap)
+				} else if isHeading(node) || isHeading(child) {
+					w.WriteString(w.opts.headingSeparator())
+				} else if tight(node, child) {
+					// Nested items follow their parent's line
+					w.WriteString("\n")
+				} else {
+					w.WriteString("\n\n")
+				}
+			}
 		} else {
-			builder.WriteString("- [ ] ")
+			w.writeGap(children[i-1], child)
 		}
-		builder.WriteString(n.Content)
-		builder.WriteString("\n")
+		w.writeNode(child, indent)
+	}
+}
+
+// writeGap writes the original whitespace between two siblings, or
+// canonical spacing
+func (w *writer) writeGap(prev, next markdown.Node) {
+	if gap, ok := w.gap(prev, next); ok {
+		w.copySource(gap)
+	} else if isHeading(prev) || isHeading(next) {
+		w.WriteString(w.opts.headingSeparator())
+	} else if tight(prev, next) {
+		// Consecutive items form a tight list
+		w.WriteString("\n")
+	} else {
+		w.WriteString("\n\n")
+	}
+}
 
-		// Don't write children for tasks as they contain duplicate content
+// writeItemBlocks writes the children of a list item without text of its
+// own, starting the first of them on the marker line. Like a blockquote's
+// children, they are written from the item's lines with the marker and
+// indentation removed, so unchanged blocks are copied from the source.
+func (w *writer) writeItemBlocks(item markdown.Node, marker, childIndent string) {
+	blocks := w.nested(item, itemPrefix())
+	children := item.Children()
+	for i, child := range children {
+		if i > 0 {
+			blocks.writeGap(children[i-1], child)
+		}
+		blocks.writeNode(child, "")
+	}
 
-	case *markdown.List:
-		// Write children (list items)
-		for i, child := range n.Children() {
-			if i > 0 {
-				// No extra spacing between list items
+	first := marker + strings.Repeat(" ", max(len(childIndent)-len(marker), 0))
+	w.writeNested(blocks, 1, func(i int, line string) string {
+		switch {
+		case i == 0:
+			return first
+		case line == "":
+			return ""
+		}
+		return childIndent
+	})
+}
+
+// itemPrefix returns a function giving the length of the marker on a list
+// item's first line, and of the indentation up to the same width on the
+// lines after it
+func itemPrefix() func(line []byte, first bool) int {
+	width := 0
+	return func(line []byte, first bool) int {
+		n := 0
+		if !first {
+			for n < width && n < len(line) && line[n] == ' ' {
+				n++
 			}
-			writeNode(builder, child, depth+1)
+			return n
+		}
+		for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+			n++
 		}
+		for n < len(line) && line[n] >= '0' && line[n] <= '9' {
+			n++
+		}
+		if n < len(line) && strings.IndexByte("-+*.)", line[n]) >= 0 {
+			n++
+		}
+		for n < len(line) && line[n] == ' ' {
+			n++
+		}
+		width = n
+		return n
+	}
+}
 
-	case *markdown.ListItem:
-		// Write list item with proper indentation
-		if depth > 0 {
-			builder.WriteString(strings.Repeat("  ", depth-1))
+// writeQuoted writes the children of a blockquote or callout with each line
+// prefixed by "> ". The children are written from the quote's lines with
+// their prefixes removed, so unchanged children and the gaps between them
+// are copied, and lines copied from the source keep their own prefix.
+func (w *writer) writeQuoted(node markdown.Node, indent string) {
+	quoted := w.nested(node, quotePrefix)
+	quoted.writeChildren(node, "")
+	w.writeNested(quoted, 0, func(i int, line string) string {
+		if line == "" {
+			return indent + ">"
+		}
+		return indent + "> "
+	})
+}
+
+// quotePrefix returns the length of a quoted line's indentation, ">" and
+// the space after it. Lazy continuation lines have no ">".
+func quotePrefix(line []byte, first bool) int {
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+	if n < len(line) && line[n] == '>' {
+		n++
+		if n < len(line) && line[n] == ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+// nested returns a writer for the content of a container whose lines carry
+// a prefix, such as a blockquote's "> ". Its source is the container's
+// lines with the prefix prefixLen reports removed from each, so that the
+// container's children can be copied from it as if they were not nested.
+func (w *writer) nested(node markdown.Node, prefixLen func(line []byte, first bool) int) *writer {
+	inner := &writer{opts: w.opts, outer: w}
+	span, ok := w.span(node)
+	if !ok {
+		return inner
+	}
+
+	var source []byte
+	for start, first := span.Start, true; ; first = false {
+		end := span.End
+		if i := bytes.IndexByte(w.source[start:span.End], '\n'); i >= 0 {
+			end = start + i
+		}
+		line := w.source[start:end]
+		prefix := min(prefixLen(line, first), len(line))
+		inner.lines = append(inner.lines, nestedLine{start: start, prefix: prefix, out: len(source)})
+		source = append(source, line[prefix:]...)
+		if end == span.End {
+			break
+		}
+		source = append(source, '\n')
+		start = end + 1
+	}
+	inner.source = source
+	return inner
+}
+
+// writeNested writes the output of a nested writer, starting each line with
+// the prefix it had in the source if it was copied from there and comes at
+// or after line from, and with the prefix returned by prefix otherwise
+func (w *writer) writeNested(inner *writer, from int, prefix func(i int, line string) string) {
+	output := inner.String()
+	copies := inner.copies
+	for i, start := 0, 0; ; i++ {
+		end := len(output)
+		if j := strings.IndexByte(output[start:], '\n'); j >= 0 {
+			end = start + j + 1
 		}
+		line := strings.TrimSuffix(output[start:end], "\n")
 
-		// Check if parent is ordered
-		if parent, ok := n.Parent().(*markdown.List); ok && parent.Ordered {
-			// For ordered lists, we'd need to track the index
-			builder.WriteString("1. ")
+		if source, ok := inner.copiedLine(start); ok && i >= from {
+			w.copySource(markdown.Span{Start: source.start, End: source.start + source.prefix})
 		} else {
-			builder.WriteString("- ")
+			w.WriteString(prefix(i, line))
 		}
 
-		builder.WriteString(n.Content)
-		builder.WriteString("\n")
+		// The parts of the line copied from the nested source were copied
+		// from the same line of this writer's source
+		out := w.Len()
+		for len(copies) > 0 && copies[0].out+copies[0].span.Len() <= start {
+			copies = copies[1:]
+		}
+		for _, c := range copies {
+			if c.out >= end {
+				break
+			}
+			lo, hi := max(c.out, start), min(c.out+c.span.Len(), end)
+			offset := c.span.Start + lo - c.out
+			source := inner.lineAt(offset)
+			offset += source.start + source.prefix - source.out
+			w.copies = append(w.copies, copied{out: out + lo - start, span: markdown.Span{Start: offset, End: offset + hi - lo}})
+		}
+		w.WriteString(output[start:end])
+
+		if end == len(output) {
+			return
+		}
+		start = end
+	}
+}
 
-		// Write children
-		for _, child := range n.Children() {
-			writeNode(builder, child, depth+1)
+// copiedLine returns the source line that the output line starting at
+// offset was copied from, if it was
+func (w *writer) copiedLine(offset int) (nestedLine, bool) {
+	for _, c := range w.copies {
+		if offset >= c.out && offset < c.out+c.span.Len() {
+			source := w.lineAt(c.span.Start + offset - c.out)
+			return source, source.out == c.span.Start+offset-c.out
 		}
+	}
+	return nestedLine{}, false
+}
 
-	case *markdown.Text:
-		builder.WriteString(n.Content)
+// lineAt returns the line of a nested writer's source containing offset
+func (w *writer) lineAt(offset int) nestedLine {
+	i := sort.Search(len(w.lines), func(i int) bool { return w.lines[i].out > offset })
+	return w.lines[max(i-1, 0)]
+}
 
-		// Write children (though text nodes typically don't have children)
-		for _, child := range n.Children() {
-			writeNode(builder, child, depth+1)
+// offset converts an offset into the document's source into one into the
+// writer's source, reporting false if it lies outside it. An offset within a
+// line's prefix is moved to the start of the line.
+func (w *writer) offset(offset int) (int, bool) {
+	if w.outer == nil {
+		return offset, true
+	}
+	offset, ok := w.outer.offset(offset)
+	if !ok || len(w.lines) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(w.lines), func(i int) bool { return w.lines[i].start > offset }) - 1
+	if i < 0 {
+		return 0, false
+	}
+	line := w.lines[i]
+	end := len(w.source)
+	if i+1 < len(w.lines) {
+		end = w.lines[i+1].out - 1
+	}
+	out := line.out + max(offset-line.start-line.prefix, 0)
+	if out > end {
+		return 0, false
+	}
+	return out, true
+}
+
+// span returns the span of a node in the writer's source
+func (w *writer) span(node markdown.Node) (markdown.Span, bool) {
+	span, ok := node.Span()
+	if !ok {
+		return markdown.Span{}, false
+	}
+	return w.translate(span)
+}
+
+// translate converts a span of the document's source into one of the
+// writer's source, reporting false if it does not lie within it
+func (w *writer) translate(span markdown.Span) (markdown.Span, bool) {
+	if w.source == nil {
+		return markdown.Span{}, false
+	}
+	start, ok := w.offset(span.Start)
+	if !ok {
+		return markdown.Span{}, false
+	}
+	end, ok := w.offset(span.End)
+	if !ok || start < 0 || start > end || end > len(w.source) {
+		return markdown.Span{}, false
+	}
+	return markdown.Span{Start: start, End: end}, true
+}
+
+// calloutLine returns a callout's first line without the "> " prefix
+func calloutLine(c *markdown.Callout) string {
+	line := "[!" + c.Kind + "]" + string(c.Fold)
+	if title := inlineText(c, "Title", c.Title); title != "" {
+		line += " " + title
+	}
+	return line
+}
+
+// writeLines writes multi-line content, prefixing the first line with first
+// and every following line with rest
+func (w *writer) writeLines(content, first, rest string) {
+	for i, line := range strings.Split(content, "\n") {
+		if i == 0 {
+			w.WriteString(first)
+		} else {
+			w.WriteString("\n")
+			w.WriteString(rest)
 		}
+		w.WriteString(line)
 	}
 }
 
-// needsSpacing determines if a node needs spacing before it
-func needsSpacing(node markdown.Node) bool {
-	switch node.(type) {
-	case *markdown.Heading, *markdown.Paragraph, *markdown.List:
-		return true
-	default:
+// writeVerbatim copies a pristine node's original source, reporting whether
+// it did so
+func (w *writer) writeVerbatim(node markdown.Node) bool {
+	if !w.pristine(node) {
 		return false
 	}
+	span, _ := w.span(node)
+	w.copySource(span)
+	return true
 }
 
-// needsExtraSpacing determines if extra spacing is needed between two consecutive nodes
-func needsExtraSpacing(prev, current markdown.Node) bool {
-	// Add extra spacing between tasks and headings
-	if _, isPrevTask := prev.(*markdown.Task); isPrevTask {
-		if _, isCurrentHeading := current.(*markdown.Heading); isCurrentHeading {
-			return true
+// pristine reports whether a node and all of its descendants are unchanged
+// since they were parsed from the writer's source
+func (w *writer) pristine(node markdown.Node) bool {
+	if _, ok := w.span(node); !ok {
+		return false
+	}
+	if selfModified(node) || w.renumbered(node) {
+		return false
+	}
+	if doc, ok := node.(*markdown.Document); ok && doc.FrontMatter != nil && !w.pristine(doc.FrontMatter) {
+		return false
+	}
+	for _, child := range node.Children() {
+		if !w.pristine(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// gap returns the original whitespace between two siblings, provided they
+// were adjacent in the source with nothing but blank space between them
+func (w *writer) gap(prev, next markdown.Node) (markdown.Span, bool) {
+	p, ok := w.span(prev)
+	if !ok {
+		return markdown.Span{}, false
+	}
+	n, ok := w.span(next)
+	if !ok {
+		return markdown.Span{}, false
+	}
+	return w.whitespace(p.End, n.Start)
+}
+
+// writeOwnVerbatim copies the lines a container node occupies before its
+// first child, provided the node itself is unmodified and only its
+// descendants have changed
+func (w *writer) writeOwnVerbatim(node markdown.Node) bool {
+	if selfModified(node) || w.renumbered(node) || len(node.Children()) == 0 {
+		return false
+	}
+	own, ok := w.ownSpan(node)
+	if !ok {
+		return false
+	}
+	w.copySource(own)
+	return true
+}
+
+// leadingGap returns the original whitespace between a parent's own lines
+// and its first child
+func (w *writer) leadingGap(parent, child markdown.Node) (markdown.Span, bool) {
+	own, ok := w.ownSpan(parent)
+	if !ok {
+		return markdown.Span{}, false
+	}
+	c, ok := w.span(child)
+	if !ok {
+		return markdown.Span{}, false
+	}
+	return w.whitespace(own.End, c.Start)
+}
+
+// ownSpan returns the span of a container's own lines if it lies within the
+// writer's source
+func (w *writer) ownSpan(node markdown.Node) (markdown.Span, bool) {
+	own, ok := node.OwnSpan()
+	if !ok {
+		return markdown.Span{}, false
+	}
+	return w.translate(own)
+}
+
+// whitespace returns the span from start to end if it is a run of blank
+// space containing at least one line ending
+func (w *writer) whitespace(start, end int) (markdown.Span, bool) {
+	if w.source == nil || start < 0 || start > end || end > len(w.source) {
+		return markdown.Span{}, false
+	}
+	gap := w.source[start:end]
+	for _, c := range gap {
+		if !isSpace(c) {
+			return markdown.Span{}, false
+		}
+	}
+	if !strings.Contains(string(gap), "\n") {
+		return markdown.Span{}, false
+	}
+	return markdown.Span{Start: start, End: end}, true
+}
+
+// copySource writes part of the source, recording where it went so that
+// its line endings can be written back the way the file wrote them
+func (w *writer) copySource(span markdown.Span) {
+	w.copies = append(w.copies, copied{out: w.Len(), span: span})
+	w.Write(w.source[span.Start:span.End])
+}
+
+// lineEndings returns output with every line ending copied from the source
+// written the way the file wrote it, and all others in the document's style
+func (w *writer) lineEndings(output string, doc *markdown.Document) string {
+	var b strings.Builder
+	copies := w.copies
+	for i := 0; i < len(output); i++ {
+		if output[i] != '\n' {
+			b.WriteByte(output[i])
+			continue
+		}
+		for len(copies) > 0 && copies[0].out+copies[0].span.End-copies[0].span.Start <= i {
+			copies = copies[1:]
+		}
+		if len(copies) > 0 && copies[0].out <= i {
+			b.WriteString(doc.LineEndingAt(copies[0].span.Start + i - copies[0].out))
+		} else {
+			b.WriteString(newline(doc))
 		}
 	}
+	return b.String()
+}
+
+// newline returns the line ending new lines in a document are written with
+func newline(doc *markdown.Document) string {
+	if doc.LineEnding == "" {
+		return markdown.LF
+	}
+	return doc.LineEnding
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isHeading reports whether node is a heading
+func isHeading(node markdown.Node) bool {
+	_, ok := node.(*markdown.Heading)
+	return ok
+}
+
+// isItem reports whether node is rendered as a list item line
+func isItem(node markdown.Node) bool {
+	switch node.(type) {
+	case *markdown.Task, *markdown.ListItem:
+		return true
+	}
 	return false
 }
 
-// WriteHeading writes an ATX heading to the builder
-func WriteHeading(builder *strings.Builder, level int, title string) {
+// tight reports whether two siblings are list items, or a list and an item,
+// that should be written without a blank line between them
+func tight(prev, next markdown.Node) bool {
+	_, prevList := prev.(*markdown.List)
+	_, nextList := next.(*markdown.List)
+	return isItem(prev) && (isItem(next) || nextList) || prevList && isItem(next)
+}
+
+// headingLine renders an ATX heading line without a line ending
+func headingLine(level int, title string) string {
 	if level < 1 || level > 6 {
 		level = 1 // Default to H1 if invalid level
 	}
+	return strings.Repeat("#", level) + " " + title
+}
+
+// headingText renders a heading in the style it was written in, followed by
+// its ID and attributes
+func headingText(n *markdown.Heading) string {
+	title := inlineText(n, "Title", n.Title)
+	attributes := n.Attributes
+	if n.ID != "" {
+		attributes = strings.TrimSpace("#" + n.ID + " " + attributes)
+	}
+	if attributes != "" {
+		title = strings.TrimSpace(title + " {" + attributes + "}")
+	}
+	if !n.Setext || n.Level > 2 || title == "" {
+		return headingLine(n.Level, title)
+	}
+	underline := "="
+	if n.Level == 2 {
+		underline = "-"
+	}
+	last := title[strings.LastIndexByte(title, '\n')+1:]
+	return title + "\n" + strings.Repeat(underline, max(utf8.RuneCountInString(last), 3))
+}
 
-	// Write the # characters
-	builder.WriteString(strings.Repeat("#", level))
-	builder.WriteString(" ")
-	builder.WriteString(title)
+// WriteHeading writes an ATX heading to the builder
+func WriteHeading(builder *strings.Builder, level int, title string) {
+	builder.WriteString(headingLine(level, title))
 	builder.WriteString("\n")
 }
 
 // WriteNode writes a single node and its children to markdown format
 func WriteNode(node markdown.Node) string {
-	var builder strings.Builder
-	writeNode(&builder, node, 0)
-	return strings.TrimSpace(builder.String())
+	w := &writer{opts: DefaultOptions()}
+
+	// Nodes attached to a parsed document can reuse its source
+	root := node
+	for root.Parent() != nil {
+		root = root.Parent()
+	}
+	if doc, ok := root.(*markdown.Document); ok {
+		w.source = doc.Source()
+	}
+
+	w.writeNode(node, "")
+	return strings.TrimRight(w.String(), "\n")
 }
 
 // CreateHeadingWithContent creates a heading with content children
@@ -285,3 +1062,67 @@ func WriteTable(headers []string, rows [][]string) string {
 
 	return builder.String()
 }
+
+// itemMarker returns the marker for a list item or task, numbering ordered
+// items the way their list is numbered
+func (w *writer) itemMarker(item markdown.Node) string {
+	opts := w.opts
+	if list, ok := item.Parent().(*markdown.List); ok && list.Ordered {
+		opts.OrderedNumbering = w.numbering(list)
+	}
+	return opts.itemMarker(item)
+}
+
+// numbering returns how an ordered list's items are numbered. A list parsed
+// from source keeps the style it was written in: the same number on every
+// item, or counting up. Other lists use the configured style.
+func (w *writer) numbering(list *markdown.List) Numbering {
+	first, count := 0, 0
+	for _, child := range list.Children() {
+		number, ok := w.sourceNumber(child)
+		if !ok {
+			continue
+		}
+		if count > 0 && number != first {
+			return NumberingSequential
+		}
+		first = number
+		count++
+	}
+	if count > 1 {
+		return NumberingLazy
+	}
+	return w.opts.OrderedNumbering
+}
+
+// renumbered reports whether an ordered list item's number has changed
+// since it was parsed from the writer's source
+func (w *writer) renumbered(node markdown.Node) bool {
+	list, ok := node.Parent().(*markdown.List)
+	if !ok || !list.Ordered {
+		return false
+	}
+	number, ok := w.sourceNumber(node)
+	if !ok {
+		return false
+	}
+	opts := w.opts
+	opts.OrderedNumbering = w.numbering(list)
+	return number != opts.itemNumber(list, node)
+}
+
+// sourceNumber returns the number an ordered list item was written with in
+// the writer's source
+func (w *writer) sourceNumber(node markdown.Node) (int, bool) {
+	span, ok := w.span(node)
+	if !ok {
+		return 0, false
+	}
+	line := strings.TrimLeft(string(w.source[span.Start:span.End]), " \t")
+	digits := 0
+	for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	number, err := strconv.Atoi(line[:digits])
+	return number, err == nil
+}
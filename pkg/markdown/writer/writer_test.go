@@ -1,12 +1,14 @@
 package writer
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
 	"github.com/notedownorg/planner/pkg/markdown"
 	"github.com/notedownorg/planner/pkg/markdown/reader"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWriteDocument(t *testing.T) {
@@ -260,7 +262,7 @@ func TestCreateTaskList(t *testing.T) {
 	for _, child := range list.Children() {
 		if task, ok := child.(*markdown.Task); ok {
 			taskCount++
-			if task.Checked {
+			if task.Checked() {
 				checkedCount++
 			}
 		}
@@ -544,3 +546,797 @@ More content.`,
 		})
 	}
 }
+
+func TestLosslessRoundTrip(t *testing.T) {
+	content := `# Week 42  #
+
+Some *emphasis*, a [link](https://example.com) and
+a second line.
+
+
+## Habits
+- [ ] Read [[Atomic Habits]]
+- [x] **Gym**
+    - [ ] Stretch
+
+## Notes
+
+* Star bullets
+* are kept`
+
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	// Untouched documents are written back byte for byte
+	assert.Equal(t, content, WriteDocument(doc))
+
+	// Toggling a task only re-renders that task
+	tasks := markdown.FindTasks(doc)
+	tasks[0].SetChecked(true)
+	expected := strings.Replace(content, "- [ ] Read", "- [x] Read", 1)
+	assert.Equal(t, expected, WriteDocument(doc))
+
+	// Adding a node keeps the rest of the document intact
+	notes := markdown.FindHeadingByTitle(doc, "Notes")
+	notes.AddChild(markdown.NewParagraph("New note."))
+	assert.Equal(t, expected+"\n\nNew note.", WriteDocument(doc))
+}
+
+func TestWriteKeepsLeadingWhitespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "Indented code block",
+			content:  "    code\n    more\n\ntext\n",
+			expected: "    code\n    more\n\ntext\n\nAdded\n",
+		},
+		{
+			name:     "Blank lines",
+			content:  "\n\n# Week 42\n\ntext",
+			expected: "\n\n# Week 42\n\ntext\n\nAdded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := reader.ParseMarkdown(tt.content)
+			require.NoError(t, err)
+			assert.Equal(t, tt.content, WriteDocument(doc))
+
+			parent := markdown.Node(doc)
+			if headings := markdown.FindHeadings(doc); len(headings) > 0 {
+				parent = headings[0]
+			}
+			parent.AddChild(markdown.NewParagraph("Added"))
+			assert.Equal(t, tt.expected, WriteDocument(doc))
+		})
+	}
+}
+
+func TestWriteNodeVerbatim(t *testing.T) {
+	content := "# Title\n\n- [x] **Done**   \n- [ ] Todo"
+
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	// Unchanged nodes are copied exactly, trailing spaces included
+	tasks := markdown.FindTasks(doc)
+	assert.Equal(t, "- [x] **Done**   ", WriteNode(tasks[0]))
+
+	tasks[1].Content = "Changed"
+	assert.Equal(t, "- [ ] Changed", WriteNode(tasks[1]))
+}
+
+func TestWriteFrontMatter(t *testing.T) {
+	content := "---\ntitle: Week 42\ntags: [weekly, review]\n---\n\n# Week 42\n\n- [ ] Gym"
+
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+	assert.Equal(t, content, WriteDocument(doc))
+
+	doc.FrontMatter.Set("reviewed", true)
+	assert.Equal(t, "---\ntitle: Week 42\ntags: [weekly, review]\nreviewed: true\n---\n\n# Week 42\n\n- [ ] Gym", WriteDocument(doc))
+
+	// Front matter can be added to documents built in code
+	fresh := markdown.NewDocument()
+	fresh.FrontMatter = markdown.NewFrontMatter()
+	fresh.FrontMatter.Set("week", 42)
+	fresh.AddChild(markdown.NewHeading(1, "Week 42"))
+	assert.Equal(t, "---\nweek: 42\n---\n\n# Week 42", WriteDocument(fresh))
+
+	// Front matter with a value that cannot be encoded is written as parsed
+	doc, err = reader.ParseMarkdown(content)
+	require.NoError(t, err)
+	doc.FrontMatter.Set("title", "Week 43")
+	doc.FrontMatter.Set("callback", func() {})
+	markdown.FindTasks(doc)[0].SetChecked(true)
+	assert.Equal(t, strings.Replace(content, "- [ ] Gym", "- [x] Gym", 1), WriteDocument(doc))
+}
+
+func TestWriteInlines(t *testing.T) {
+	content := "# Habits\n\n- [ ] Read [[Atomic Habits]] *daily*\n- [x] **Gym**"
+
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	// Editing an inline node re-renders only the block containing it
+	tasks := markdown.FindTasks(doc)
+	tasks[0].Inlines[1].(*markdown.WikiLink).Alias = "the book"
+	assert.Equal(t, "# Habits\n\n- [ ] Read [[Atomic Habits|the book]] *daily*\n- [x] **Gym**", WriteDocument(doc))
+
+	// Editing the raw content takes precedence over stale inline nodes
+	tasks[1].Content = "**Gym** and swim"
+	assert.Contains(t, WriteDocument(doc), "- [x] **Gym** and swim")
+
+	// Inline nodes built in code are rendered when there is no raw content
+	para := markdown.NewParagraph("")
+	para.SetInlines(
+		markdown.NewText("See "),
+		markdown.NewLink("https://example.com", "Home", markdown.NewText("the site")),
+		markdown.NewText(", "),
+		markdown.NewImage("chart.png", "", markdown.NewText("chart")),
+		markdown.NewText(" and "),
+		markdown.NewCodeSpan("a`b"),
+		markdown.NewText(" "),
+		markdown.NewEmphasis("_", markdown.NewText("now")),
+	)
+	assert.Equal(t, "See [the site](https://example.com \"Home\"), ![chart](chart.png) and ``a`b`` _now_", WriteNode(para))
+}
+
+func TestWriteBlocks(t *testing.T) {
+	doc := markdown.NewDocument()
+	heading := markdown.NewHeading(1, "Week 42")
+	doc.AddChild(heading)
+
+	heading.AddChild(markdown.NewCodeBlock("go", "fmt.Println()\n\nfmt.Println()"))
+	heading.AddChild(markdown.NewThematicBreak(""))
+
+	quote := markdown.NewBlockquote()
+	quote.AddChild(markdown.NewParagraph("Quoted"))
+	quote.AddChild(markdown.NewTask(false, "Quoted task"))
+	heading.AddChild(quote)
+
+	fence := markdown.NewCodeBlock("", "```inner```")
+	heading.AddChild(fence)
+
+	indented := markdown.NewCodeBlock("", "indented")
+	indented.Fenced = false
+	heading.AddChild(indented)
+
+	expected := "# Week 42\n\n```go\nfmt.Println()\n\nfmt.Println()\n```\n\n---\n\n> Quoted\n>\n> - [ ] Quoted task\n\n````\n```inner```\n````\n\n    indented"
+	assert.Equal(t, expected, WriteDocument(doc))
+
+	// Parsed blocks survive edits to their neighbours
+	parsed, err := reader.ParseMarkdown(expected + "\n\n- [ ] Gym")
+	assert.NoError(t, err)
+	markdown.FindTasks(parsed)[1].SetChecked(true)
+	assert.Equal(t, expected+"\n\n- [x] Gym", WriteDocument(parsed))
+}
+
+func TestWriteBlockquoteEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "Paragraph and task",
+			content:  "> quote **x**\n> - [ ] t",
+			expected: "> quote **x**\n> - [x] t",
+		},
+		{
+			name:     "Unusual prefixes",
+			content:  ">quote\nlazy\n>\n>  - [ ] t\n>  - [ ] u",
+			expected: ">quote\nlazy\n>\n> - [x] t\n>  - [ ] u",
+		},
+		{
+			name:     "Nested in a list item",
+			content:  "- a\n  > q\n  >\n  > > inner\n  > > - [ ] t\n- b",
+			expected: "- a\n  > q\n  >\n  > > inner\n  > > - [x] t\n- b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := reader.ParseMarkdown(tt.content)
+			require.NoError(t, err)
+			markdown.FindAllTasks(doc)[0].SetChecked(true)
+			assert.Equal(t, tt.expected, WriteDocument(doc))
+		})
+	}
+}
+
+func TestWriteTableNode(t *testing.T) {
+	table := markdown.NewTable(markdown.AlignLeft, markdown.AlignCenter, markdown.AlignRight)
+	table.AddChild(markdown.NewTableRow(true, "Habit", "Streak", "Days"))
+	table.AddChild(markdown.NewTableRow(false, "Gym", "ok", "3"))
+	table.AddChild(markdown.NewTableRow(false, "Read a|b", "", "12"))
+	table.AddChild(markdown.NewTableRow(false, "Extra"))
+
+	doc := markdown.NewDocument()
+	doc.AddChild(table)
+
+	expected := "| Habit     | Streak | Days |\n" +
+		"| :-------- | :----: | ---: |\n" +
+		"| Gym       |   ok   |    3 |\n" +
+		"| Read a\\|b |        |   12 |\n" +
+		"| Extra     |        |      |"
+	assert.Equal(t, expected, WriteDocument(doc))
+
+	// Untouched tables are copied as written, edited ones are realigned
+	content := "# Habits\n\n|Habit|Days|\n|-|-|\n|Gym|3|\n\n- [ ] Gym"
+	parsed, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+	assert.Equal(t, content, WriteDocument(parsed))
+
+	parsedTable := markdown.FindHeadingByTitle(parsed, "Habits").Children()[0].(*markdown.Table)
+	parsedTable.Rows()[1].Cells()[1].Content = "12"
+	assert.Equal(t, "# Habits\n\n| Habit | Days |\n| ----- | ---- |\n| Gym   | 12   |\n\n- [ ] Gym", WriteDocument(parsed))
+}
+
+func TestWriteSubtasks(t *testing.T) {
+	doc := markdown.NewDocument()
+	heading := markdown.NewHeading(2, "Habits")
+	doc.AddChild(heading)
+
+	exercise := markdown.NewTask(false, "Exercise")
+	exercise.AddChild(markdown.NewTask(true, "Stretch"))
+	run := markdown.NewTask(false, "Run")
+	run.AddChild(markdown.NewTask(false, "5km"))
+	exercise.AddChild(run)
+	heading.AddChild(exercise)
+	heading.AddChild(markdown.NewTask(false, "Read"))
+
+	expected := "## Habits\n\n- [ ] Exercise\n  - [x] Stretch\n  - [ ] Run\n    - [ ] 5km\n- [ ] Read"
+	assert.Equal(t, expected, WriteDocument(doc))
+
+	// Parsed subtasks keep their hierarchy and are re-indented when edited
+	parsed, err := reader.ParseMarkdown(expected)
+	assert.NoError(t, err)
+	tasks := markdown.FindTasks(parsed)
+	assert.Len(t, tasks, 2)
+	subtasks := tasks[0].Subtasks()
+	assert.Len(t, subtasks, 2)
+	assert.Equal(t, "5km", subtasks[1].Subtasks()[0].Content)
+
+	subtasks[1].Subtasks()[0].SetChecked(true)
+	subtasks[1].AddChild(markdown.NewTask(false, "Cool down"))
+	assert.Equal(t, "## Habits\n\n- [ ] Exercise\n  - [x] Stretch\n  - [ ] Run\n    - [x] 5km\n    - [ ] Cool down\n- [ ] Read", WriteDocument(parsed))
+}
+
+func TestWriteTaskStatuses(t *testing.T) {
+	doc := markdown.NewDocument()
+	doc.AddChild(markdown.NewTaskWithStatus(markdown.StatusInProgress, "Write report"))
+	doc.AddChild(markdown.NewTaskWithStatus(markdown.StatusScheduled, "Dentist"))
+	doc.AddChild(markdown.NewTask(true, "Gym"))
+	assert.Equal(t, "- [/] Write report\n- [<] Dentist\n- [x] Gym", WriteDocument(doc))
+
+	// Statuses survive edits to the task's content
+	content := "- [X] Read\n- [-] Cancelled\n- [>] Migrated"
+	parsed, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+	tasks := markdown.FindTasks(parsed)
+	for _, task := range tasks {
+		task.Content += "!"
+	}
+	tasks[0].SetChecked(true)
+	assert.Equal(t, "- [X] Read!\n- [-] Cancelled!\n- [>] Migrated!", WriteDocument(parsed))
+}
+
+func TestWriteReorderedChildren(t *testing.T) {
+	content := "## Habits\n\n- [x] Exercise\n- [ ] Read\n- [ ] Journal"
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	// The heading's own line is not confused with a moved sibling
+	heading := markdown.FindHeadingByTitle(doc, "Habits")
+	tasks := markdown.FindTasks(heading)
+	heading.ClearChildren()
+	heading.AddChild(tasks[2])
+	heading.AddChild(tasks[0])
+	assert.Equal(t, "## Habits\n\n- [ ] Journal\n- [x] Exercise", WriteDocument(doc))
+}
+
+func TestWriteTaskMetadata(t *testing.T) {
+	content := "## Tasks\n\n- [ ] Pay rent 📅 2026-10-20 ⏫ 🔁 every month\n- [ ] Read [author:: Le Guin]"
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	tasks := markdown.FindTasks(doc)
+	assert.Equal(t, markdown.PriorityHigh, tasks[0].Metadata.Priority)
+	assert.Equal(t, "every month", tasks[0].Metadata.Recurrence)
+	assert.Equal(t, "2026-10-20", tasks[0].Metadata.Due.Format(markdown.DateFormat))
+	assert.Equal(t, "Le Guin", tasks[1].Metadata.Fields["author"])
+	assert.Equal(t, content, WriteDocument(doc))
+
+	meta := tasks[0].Metadata
+	meta.Due = meta.Due.AddDate(0, 1, 0)
+	tasks[0].SetMetadata(meta)
+	assert.Equal(t, "## Tasks\n\n- [ ] Pay rent 📅 2026-11-20 ⏫ 🔁 every month\n- [ ] Read [author:: Le Guin]", WriteDocument(doc))
+}
+
+func TestWriteLinksAndTags(t *testing.T) {
+	content := "- [ ] Read [[Atomic Habits#Chapter 1]] #reading"
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+	assert.Equal(t, content, WriteDocument(doc))
+
+	task := markdown.FindTasks(doc)[0]
+	markdown.FindTags(task)[0].Name = "reading/books"
+	markdown.FindLinks(task)[0].Heading = "Chapter 2"
+	assert.Equal(t, "- [ ] Read [[Atomic Habits#Chapter 2]] #reading/books", WriteDocument(doc))
+}
+
+func TestWriteAfterTreeMutation(t *testing.T) {
+	content := "## Habits\n\n- [ ] Exercise\n- [ ] Read\n\nNotes about *reading*.\n\n- [ ] Journal"
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	tasks := markdown.FindTasks(doc)
+	tasks[1].Parent().RemoveChild(tasks[1])
+	tasks[2].Parent().InsertBefore(markdown.NewTask(false, "Meditate"), tasks[2])
+	assert.Equal(t, "## Habits\n\n- [ ] Exercise\n\nNotes about *reading*.\n\n- [ ] Meditate\n- [ ] Journal", WriteDocument(doc))
+
+	tasks[0].ReplaceWith(markdown.NewTask(true, "Run"))
+	assert.Equal(t, "## Habits\n\n- [x] Run\n\nNotes about *reading*.\n\n- [ ] Meditate\n- [ ] Journal", WriteDocument(doc))
+}
+
+func TestWriteAfterTransform(t *testing.T) {
+	content := "## Habits\n\n- [x] Gym #health **hard**\n- [x] Read #books\n\nKeep it *up*."
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	markdown.Transform(doc, func(n markdown.Node) markdown.Node {
+		switch n := n.(type) {
+		case *markdown.Task:
+			n.SetChecked(false)
+		case *markdown.Tag:
+			if n.Name == "health" {
+				return markdown.NewTag("fitness")
+			}
+		}
+		return n
+	})
+	assert.Equal(t, "## Habits\n\n- [ ] Gym #fitness **hard**\n- [ ] Read #books\n\nKeep it *up*.", WriteDocument(doc))
+}
+
+func TestWriteDocumentWithOptions(t *testing.T) {
+	buildDoc := func() *markdown.Document {
+		doc := markdown.NewDocument()
+		heading := markdown.NewHeading(1, "Week 42")
+		doc.AddChild(heading)
+		heading.AddChild(markdown.NewParagraph("Notes"))
+
+		tasks := markdown.NewList(false)
+		gym := markdown.NewTask(true, "Gym")
+		nested := markdown.NewList(false)
+		nested.AddChild(markdown.NewListItem("Stretch"))
+		gym.AddChild(nested)
+		tasks.AddChild(gym)
+		tasks.AddChild(markdown.NewTask(false, "Read"))
+		heading.AddChild(tasks)
+
+		steps := markdown.NewList(true)
+		steps.AddChild(markdown.NewListItem("Plan"))
+		steps.AddChild(markdown.NewListItem("Review"))
+		doc.AddChild(markdown.NewHeading(2, "Steps"))
+		doc.Children()[1].AddChild(steps)
+		return doc
+	}
+
+	tests := []struct {
+		name     string
+		opts     func(*Options)
+		expected string
+	}{
+		{
+			name:     "Defaults",
+			opts:     func(*Options) {},
+			expected: "# Week 42\n\nNotes\n\n- [x] Gym\n  - Stretch\n- [ ] Read\n\n## Steps\n\n1. Plan\n2. Review",
+		},
+		{
+			name: "Markdownlint style",
+			opts: func(o *Options) {
+				o.BulletMarker = "*"
+				o.CheckedChar = 'X'
+				o.IndentWidth = 4
+				o.OrderedNumbering = NumberingLazy
+				o.TrailingNewline = true
+			},
+			expected: "# Week 42\n\nNotes\n\n* [X] Gym\n    * Stretch\n* [ ] Read\n\n## Steps\n\n1. Plan\n1. Review\n",
+		},
+		{
+			name: "Invalid checked character",
+			opts: func(o *Options) {
+				o.CheckedChar = 'v'
+			},
+			expected: "# Week 42\n\nNotes\n\n- [x] Gym\n  - Stretch\n- [ ] Read\n\n## Steps\n\n1. Plan\n2. Review",
+		},
+		{
+			name: "No blank lines around headings",
+			opts: func(o *Options) {
+				o.BlankLinesAroundHeadings = 0
+			},
+			expected: "# Week 42\nNotes\n\n- [x] Gym\n  - Stretch\n- [ ] Read\n## Steps\n1. Plan\n2. Review",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.opts(&opts)
+			assert.Equal(t, tt.expected, WriteDocumentWithOptions(buildDoc(), opts))
+		})
+	}
+}
+
+func TestWriteOptionsKeepVerbatimNodes(t *testing.T) {
+	content := "# Week 42\n- [x] Gym\n- [ ] Read"
+	doc, err := reader.ParseMarkdown(content)
+	assert.NoError(t, err)
+
+	opts := DefaultOptions()
+	opts.CheckedChar = 'X'
+	opts.TrailingNewline = true
+
+	// Unchanged nodes keep the style they were written in
+	assert.Equal(t, content+"\n", WriteDocumentWithOptions(doc, opts))
+
+	tasks := markdown.FindTasks(doc)
+	tasks[1].SetChecked(true)
+	assert.Equal(t, "# Week 42\n- [x] Gym\n- [X] Read\n", WriteDocumentWithOptions(doc, opts))
+}
+
+func TestWriteListNumbering(t *testing.T) {
+	doc, err := reader.ParseMarkdown("3) Plan\n4) Build\n   1. Design\n   2. Code\n5) Ship")
+	assert.NoError(t, err)
+
+	outer := doc.Children()[0].(*markdown.List)
+	assert.Equal(t, 3, outer.Start)
+	assert.Equal(t, ")", outer.Marker)
+	outer.AddChild(markdown.NewListItem("Review"))
+
+	nested := outer.Children()[1].Children()[0].(*markdown.List)
+	assert.Equal(t, 1, nested.Start)
+	assert.Equal(t, ".", nested.Marker)
+	nested.InsertChild(1, markdown.NewListItem("Test"))
+
+	// Items after the insertion are renumbered, keeping each list's start
+	// number and delimiter
+	opts := DefaultOptions()
+	opts.OrderedNumbering = NumberingLazy
+	assert.Equal(t, "3) Plan\n4) Build\n   1. Design\n   2. Test\n   3. Code\n5) Ship\n6) Review", WriteDocumentWithOptions(doc, opts))
+}
+
+func TestWriteListKeepsLazyNumbering(t *testing.T) {
+	doc, err := reader.ParseMarkdown("1. Plan\n1. Build")
+	assert.NoError(t, err)
+
+	// A list written with the same number on every item keeps that style
+	doc.Children()[0].AddChild(markdown.NewListItem("Ship"))
+	assert.Equal(t, "1. Plan\n1. Build\n1. Ship", WriteDocument(doc))
+}
+
+func TestWriteListContinuity(t *testing.T) {
+	doc, err := reader.ParseMarkdown("* [ ] Gym\n* [ ] Read")
+	assert.NoError(t, err)
+
+	// A rewritten item keeps its list's bullet rather than starting a new list
+	markdown.FindTasks(doc)[1].SetChecked(true)
+	assert.Equal(t, "* [ ] Gym\n* [x] Read", WriteDocument(doc))
+
+	// A new list next to one with the same bullet switches bullets so the
+	// two are not read back as one list
+	doc, err = reader.ParseMarkdown("- Gym\n- Read")
+	assert.NoError(t, err)
+	list := markdown.NewList(false)
+	list.AddChild(markdown.NewListItem("Journal"))
+	doc.AddChild(list)
+	output := WriteDocument(doc)
+	assert.Equal(t, "- Gym\n- Read\n\n* Journal", output)
+
+	reparsed, err := reader.ParseMarkdown(output)
+	assert.NoError(t, err)
+	assert.Len(t, reparsed.Children(), 2)
+}
+
+func TestWriteAfterJSONRoundTrip(t *testing.T) {
+	content := "---\nstatus: draft # keep\n---\n# Week 42\n\n## Habits\n*   [ ] Gym\n*   [x] Read\n\n| a | b |\n|---|:-:|\n| 1 | 2 |"
+	doc, err := reader.ParseMarkdown(content)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	// Unchanged nodes are still copied from the source
+	var decoded markdown.Document
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, content, WriteDocument(&decoded))
+
+	// Edits made in code are written as usual
+	markdown.FindTasks(&decoded)[0].SetChecked(true)
+	assert.Equal(t, strings.Replace(content, "*   [ ] Gym", "* [x] Gym", 1), WriteDocument(&decoded))
+}
+
+func TestWriteAfterJSONEdits(t *testing.T) {
+	content := "# Week 42\n\n*   [ ] Gym 📅 2026-10-20\n*   [x] Read\n*   [ ] Walk\n\nSome *notes*"
+
+	tests := []struct {
+		name     string
+		edit     func(doc map[string]any)
+		expected string
+	}{
+		{
+			name: "Field",
+			edit: func(doc map[string]any) {
+				task(doc, 0)["status"] = "x"
+			},
+			expected: "# Week 42\n\n* [x] Gym 📅 2026-10-20\n*   [x] Read\n*   [ ] Walk\n\nSome *notes*",
+		},
+		{
+			name: "Metadata",
+			edit: func(doc map[string]any) {
+				task(doc, 0)["metadata"] = map[string]any{"due": "2026-10-21"}
+			},
+			expected: "# Week 42\n\n* [ ] Gym 📅 2026-10-21\n*   [x] Read\n*   [ ] Walk\n\nSome *notes*",
+		},
+		{
+			name: "Removed child",
+			edit: func(doc map[string]any) {
+				list := child(child(doc, 0), 0)
+				items := list["children"].([]any)
+				list["children"] = append(items[:1], items[2:]...)
+			},
+			expected: "# Week 42\n\n*   [ ] Gym 📅 2026-10-20\n*   [ ] Walk\n\nSome *notes*",
+		},
+		{
+			name: "Inline",
+			edit: func(doc map[string]any) {
+				paragraph := child(child(doc, 0), 1)
+				emphasis := paragraph["inlines"].([]any)[1].(map[string]any)
+				emphasis["children"].([]any)[0].(map[string]any)["content"] = "plans"
+			},
+			expected: "# Week 42\n\n*   [ ] Gym 📅 2026-10-20\n*   [x] Read\n*   [ ] Walk\n\nSome *plans*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := reader.ParseMarkdown(content)
+			require.NoError(t, err)
+			data, err := json.Marshal(doc)
+			require.NoError(t, err)
+
+			var edited map[string]any
+			require.NoError(t, json.Unmarshal(data, &edited))
+			tt.edit(edited)
+			data, err = json.Marshal(edited)
+			require.NoError(t, err)
+
+			var decoded markdown.Document
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, tt.expected, WriteDocument(&decoded))
+		})
+	}
+}
+
+// child returns the JSON form of a node's child at index
+func child(node map[string]any, index int) map[string]any {
+	return node["children"].([]any)[index].(map[string]any)
+}
+
+// task returns the JSON form of the task at index in the first list under
+// the first heading
+func task(doc map[string]any, index int) map[string]any {
+	return child(child(child(doc, 0), 0), index)
+}
+
+func TestWriteKeepsRawBlocks(t *testing.T) {
+	content := "# Week 42\n\n<div align=\"center\">\n  <img src=\"chart.png\">\n</div>\n\n- [ ] Gym\n  <br>\n\n  <!-- keep -->\n\nSee [docs][] and the note[^1].\n\n[docs]: https://example.com\n[^1]: A footnote"
+	doc, err := reader.ParseMarkdown(content)
+	require.NoError(t, err)
+
+	// Edits around the raw blocks leave them as written
+	markdown.FindTasks(doc)[0].SetChecked(true)
+	week := markdown.FindHeadingByTitle(doc, "Week 42")
+	week.AddChild(markdown.NewParagraph("Went hiking."))
+	week.AddChild(markdown.NewRawBlock(markdown.RawHTML, "<hr class=\"end\">"))
+
+	expected := strings.Replace(content, "- [ ] Gym", "- [x] Gym", 1) + "\n\nWent hiking.\n\n<hr class=\"end\">"
+	assert.Equal(t, expected, WriteDocument(doc))
+}
+
+func TestWriteKeepsTrailingDefinitions(t *testing.T) {
+	for _, content := range []string{
+		"See.\n\n[docs]: https://example.com\n",
+		"# H\n\n- [ ] a\n\n[^1]: note\n",
+	} {
+		t.Run(content, func(t *testing.T) {
+			doc, err := reader.ParseMarkdown(content)
+			require.NoError(t, err)
+
+			// The definitions are the only thing after the last block
+			children := doc.Children()
+			last := children[len(children)-1]
+			if heading, ok := last.(*markdown.Heading); ok {
+				last = heading.Children()[len(heading.Children())-1]
+			}
+			assert.IsType(t, &markdown.RawBlock{}, last)
+			assert.Equal(t, content, WriteDocument(doc))
+		})
+	}
+}
+
+func TestWriteCallouts(t *testing.T) {
+	content := "## Review\n\n> [!NOTE]+   What went *well*\n> Gym three times.\n>\n> - [x] Ship\n\n> [!warning]-\n> Sleep slipped"
+	doc, err := reader.ParseMarkdown(content)
+	require.NoError(t, err)
+	assert.Equal(t, content, WriteDocument(doc))
+
+	// Editing a callout's content keeps its first line as written
+	callouts := markdown.FindCallouts(doc)
+	markdown.FindTasks(callouts[0])[0].SetChecked(false)
+	assert.Equal(t, strings.Replace(content, "- [x] Ship", "- [ ] Ship", 1), WriteDocument(doc))
+
+	// Editing the callout itself rewrites its first line
+	callouts[1].Fold = markdown.FoldExpanded
+	callouts[1].Title = "Sleep"
+	assert.Equal(t, strings.Replace(strings.Replace(content, "- [x] Ship", "- [ ] Ship", 1), "[!warning]-", "[!warning]+ Sleep", 1), WriteDocument(doc))
+
+	// New callouts are written in the standard form
+	review := markdown.NewCallout("tip", "Next week")
+	review.AddChild(markdown.NewParagraph("Start earlier."))
+	review.AddChild(markdown.NewTask(false, "Plan Monday"))
+	doc.AddChild(review)
+	assert.True(t, strings.HasSuffix(WriteDocument(doc), "\n\n> [!tip] Next week\n> Start earlier.\n>\n> - [ ] Plan Monday"))
+}
+
+func TestWriteCalloutEdits(t *testing.T) {
+	content := "> [!todo] Review\r\n> Went *well*:  \n> gym\n> * [ ] follow up\n> * [ ] call\n>\n> | a | b |\n> |---|---|\n> | 1 | 2 |\n"
+	doc, err := reader.ParseMarkdown(content)
+	require.NoError(t, err)
+
+	// Only the edited task's line changes
+	markdown.FindAllTasks(doc)[0].SetChecked(true)
+	expected := strings.Replace(content, "> * [ ] follow up", "> * [x] follow up", 1)
+	assert.Equal(t, expected, WriteDocument(doc))
+
+	// As does only the title line when the title is edited
+	markdown.FindCallouts(doc)[0].Title = "Retro"
+	expected = strings.Replace(expected, "Review", "Retro", 1)
+	assert.Equal(t, expected, WriteDocument(doc))
+}
+
+func TestWriteBlockIDs(t *testing.T) {
+	content := "- [ ] Gym ^gym\n- Read ^read\n\nA note ^note"
+	doc, err := reader.ParseMarkdown(content)
+	require.NoError(t, err)
+	assert.Equal(t, content, WriteDocument(doc))
+
+	// Rewritten blocks keep their IDs
+	gym := markdown.FindByBlockID(doc, "gym").(*markdown.Task)
+	gym.Content = "Gym for an hour"
+	gym.SetChecked(true)
+	markdown.FindByBlockID(doc, "read").(*markdown.ListItem).Content = "Read a chapter"
+	markdown.FindByBlockID(doc, "note").(*markdown.Paragraph).Content = "A longer note"
+	assert.Equal(t, "- [x] Gym for an hour ^gym\n- Read a chapter ^read\n\nA longer note ^note", WriteDocument(doc))
+}
+
+func TestWriteKeepsLineEndings(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "LF with final newline",
+			content:  "# Week 42\n\n- [ ] Gym\n- [ ] Read\n",
+			expected: "# Week 42\n\n- [x] Gym\n- [ ] Read\n\nWent hiking.\n",
+		},
+		{
+			name:     "CRLF",
+			content:  "# Week 42\r\n\r\n- [ ] Gym\r\n- [ ] Read",
+			expected: "# Week 42\r\n\r\n- [x] Gym\r\n- [ ] Read\r\n\r\nWent hiking.",
+		},
+		{
+			name:     "Trailing blank lines",
+			content:  "# Week 42\n\n- [ ] Gym\n- [ ] Read\n\n\n",
+			expected: "# Week 42\n\n- [x] Gym\n- [ ] Read\n\nWent hiking.\n\n\n",
+		},
+		{
+			name:     "Trailing hard break",
+			content:  "# Week 42\n\n- [ ] Gym\n- [ ] Read\n\ntext  \n",
+			expected: "# Week 42\n\n- [x] Gym\n- [ ] Read\n\ntext  \n\nWent hiking.\n",
+		},
+		{
+			name:     "Mixed line endings",
+			content:  "# Week 42\r\n\r\n- [ ] Gym\n- [ ] Read\r\n",
+			expected: "# Week 42\r\n\r\n- [x] Gym\n- [ ] Read\r\n\r\nWent hiking.\r\n",
+		},
+		{
+			name:     "BOM and CRLF with final newline",
+			content:  "\uFEFF---\r\nweek: 42\r\n---\r\n# Week 42\r\n\r\n- [ ] Gym\r\n- [ ] Read\r\n",
+			expected: "\uFEFF---\r\nweek: 42\r\n---\r\n# Week 42\r\n\r\n- [x] Gym\r\n- [ ] Read\r\n\r\nWent hiking.\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := reader.ParseMarkdown(tt.content)
+			require.NoError(t, err)
+			assert.Equal(t, tt.content, WriteDocument(doc))
+
+			// New and rewritten lines follow the file's style
+			markdown.FindTasks(doc)[0].SetChecked(true)
+			markdown.FindHeadingByTitle(doc, "Week 42").AddChild(markdown.NewParagraph("Went hiking."))
+			assert.Equal(t, tt.expected, WriteDocument(doc))
+		})
+	}
+}
+
+func TestWriteHeadingStyles(t *testing.T) {
+	content := "Week 42\n=======\n\nHabits {#habits}\n------\n\n- [ ] Gym\n\n## Notes {#my-notes .wide} ##\n\nNothing yet"
+	doc, err := reader.ParseMarkdown(content)
+	require.NoError(t, err)
+	assert.Equal(t, content, WriteDocument(doc))
+
+	// Rewritten headings keep their style and attributes
+	for _, heading := range markdown.FindHeadings(doc) {
+		heading.Title += " (done)"
+	}
+	assert.Equal(t, "Week 42 (done)\n==============\n\nHabits (done) {#habits}\n-----------------------\n\n- [ ] Gym\n\n## Notes (done) {#my-notes .wide}\n\nNothing yet", WriteDocument(doc))
+
+	// Setext style only goes up to level 2
+	habits := markdown.FindHeadingByID(doc, "habits")
+	habits.Level = 3
+	habits.ID = ""
+	assert.Equal(t, "Week 42 (done)\n==============\n\n### Habits (done)\n\n- [ ] Gym\n\n## Notes (done) {#my-notes .wide}\n\nNothing yet", WriteDocument(doc))
+}
+
+func TestWriteRichListItems(t *testing.T) {
+	content := "- [ ] Plan trip\ncheck dates\n\n  Book a hotel  \n  near the station\n\n  ```sh\n  ls -la\n  ```\n- ```go\n  code\n  ```\n- Last"
+	doc, err := reader.ParseMarkdown(content)
+	require.NoError(t, err)
+	assert.Equal(t, content, WriteDocument(doc))
+
+	// Rewritten items keep their blocks, indented under the item
+	markdown.Walk(doc, func(node markdown.Node, entering bool) markdown.WalkStatus {
+		node.MarkModified()
+		return markdown.WalkContinue
+	})
+	assert.Equal(t, "- [ ] Plan trip\n  check dates\n\n  Book a hotel  \n  near the station\n\n  ```sh\n  ls -la\n  ```\n- ```go\n  code\n  ```\n- Last", WriteDocument(doc))
+
+	// Editing a block of an item that starts with one keeps the item's
+	// other blocks as written
+	content = "- > q\n  > - [ ] t\n\n  Some *para*  \n  text\n\n  | a | b |\n  |---|---|\n- Last"
+	doc, err = reader.ParseMarkdown(content)
+	require.NoError(t, err)
+	markdown.FindAllTasks(doc)[0].SetChecked(true)
+	assert.Equal(t, strings.Replace(content, "[ ] t", "[x] t", 1), WriteDocument(doc))
+
+	// Blocks added to an item are separated from its text so they are
+	// read back as blocks of their own
+	built := markdown.NewDocument()
+	list := markdown.NewList(true)
+	task := markdown.NewTask(false, "Plan trip")
+	task.AddChild(markdown.NewParagraph("Book a hotel"))
+	task.AddChild(markdown.NewTask(false, "Pack"))
+	list.AddChild(task)
+	item := markdown.NewListItem("")
+	item.AddChild(markdown.NewCodeBlock("sh", "ls -la"))
+	list.AddChild(item)
+	built.AddChild(list)
+
+	opts := DefaultOptions()
+	opts.IndentWidth = 4
+	output := WriteDocumentWithOptions(built, opts)
+	assert.Equal(t, "1. [ ] Plan trip\n\n    Book a hotel\n\n    - [ ] Pack\n2.  ```sh\n    ls -la\n    ```", output)
+
+	again, err := reader.ParseMarkdown(output)
+	require.NoError(t, err)
+	items := again.Children()[0].Children()
+	assert.Equal(t, "Book a hotel", items[0].Children()[0].(*markdown.Paragraph).Content)
+	assert.Equal(t, "ls -la", items[1].Children()[0].(*markdown.CodeBlock).Content)
+}